@@ -3,7 +3,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // 应用程序配置
@@ -17,6 +19,21 @@ var (
 	// Timeout HTTP 请求超时时间（秒）
 	Timeout = getIntEnvOrDefault("CNFAST_TIMEOUT", 30)
 
+	// PullConcurrency 批量拉取镜像时的并发数
+	PullConcurrency = getIntEnvOrDefault("CNFAST_PULL_CONCURRENCY", 3)
+
+	// ForwardCredentials 允许接收原始仓库凭据的镜像源或加速域名（逗号分隔），默认不转发
+	ForwardCredentials = getListEnv("CNFAST_FORWARD_CREDENTIALS")
+
+	// DirectFallback 所有 docker 代理失败后的直连策略: never / ask / always
+	DirectFallback = getEnvOrDefault("CNFAST_DIRECT_FALLBACK", "ask")
+
+	// DockerAPI 是否优先通过 Docker Engine API 访问 dockerd，不可用时回退到 docker 命令行
+	DockerAPI = getBoolEnvOrDefault("CNFAST_DOCKER_API", true)
+
+	// HomeDir cnfast 本地数据目录，用于保存记录文件与缓存
+	HomeDir = getEnvOrDefault("CNFAST_HOME", defaultHomeDir())
+
 	// Version 应用程序版本
 	Version = "1.0.0"
 
@@ -33,6 +50,15 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// defaultHomeDir 返回默认的本地数据目录（~/.cnfast）
+func defaultHomeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".cnfast"
+	}
+	return filepath.Join(home, ".cnfast")
+}
+
 // getBoolEnvOrDefault 获取布尔类型环境变量，如果不存在则返回默认值
 func getBoolEnvOrDefault(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
@@ -43,6 +69,17 @@ func getBoolEnvOrDefault(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getListEnv 获取逗号分隔的列表类型环境变量，忽略空白项
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
 // getIntEnvOrDefault 获取整数类型环境变量，如果不存在则返回默认值
 func getIntEnvOrDefault(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
@@ -0,0 +1,56 @@
+// Package config 包含用户配置文件的加载逻辑
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// UserConfig 用户配置文件（默认 ~/.cnfast/config.yaml）
+type UserConfig struct {
+	// RegistryMapping 镜像源到加速域名的映射模板，覆盖内置映射与服务端下发的映射
+	// 模板中的 {proxy} 会被替换为当前 docker 代理地址，{host} 为镜像源域名，
+	// {path} 为仓库路径；值为空表示该镜像源不加速
+	RegistryMapping map[string]string `yaml:"registryMapping"`
+
+	// GitURLTemplate git 加速地址模板，覆盖代理服务下发的模板，如 "{proxy}/{host}/{path}"
+	GitURLTemplate string `yaml:"gitUrlTemplate"`
+
+	// ImageTemplate 镜像地址模板，覆盖代理服务下发的模板，如 "{proxy}/{host}/{path}"
+	ImageTemplate string `yaml:"imageTemplate"`
+
+	// ForwardCredentials 允许接收原始仓库凭据的镜像源或加速域名，如 ghcr.io、docker.example.com，
+	// 与环境变量 CNFAST_FORWARD_CREDENTIALS 合并
+	ForwardCredentials []string `yaml:"forwardCredentials"`
+}
+
+var (
+	// ConfigFile 用户配置文件路径
+	ConfigFile = getEnvOrDefault("CNFAST_CONFIG", filepath.Join(HomeDir, "config.yaml"))
+
+	// User 用户配置，配置文件不存在时为空配置
+	User = loadUserConfig(ConfigFile)
+)
+
+// loadUserConfig 读取用户配置文件
+// 文件不存在时返回空配置；解析失败时输出警告并返回空配置
+func loadUserConfig(path string) *UserConfig {
+	cfg := &UserConfig{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "警告: 读取配置文件 %s 失败: %v\n", path, err)
+		}
+		return cfg
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 解析配置文件 %s 失败: %v\n", path, err)
+		return &UserConfig{}
+	}
+	return cfg
+}
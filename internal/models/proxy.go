@@ -26,6 +26,20 @@ type ProxyItem struct {
 
 	// ProxyType 代理类型，如 "docker" 或 "git"
 	ProxyType string `json:"proxyType"`
+
+	// SupportPush 代理是否支持推送镜像（仅 docker 代理有效）
+	SupportPush bool `json:"supportPush"`
+
+	// RegistryMapping 镜像源到加速域名的映射模板（仅 docker 代理有效）
+	// 例如 {"mcr.microsoft.com": "mcr.{proxy}"}，会覆盖内置映射
+	RegistryMapping map[string]string `json:"registryMapping,omitempty"`
+
+	// URLTemplate 加速地址模板（仅 git 代理有效），如 "{proxy}/{url}"、"{proxy}/gh/{path}"
+	URLTemplate string `json:"urlTemplate,omitempty"`
+
+	// ImageTemplate 镜像地址模板（仅 docker 代理有效），如 "{proxy}/{host}/{path}"
+	// 设置后替代内置映射，对所有已知镜像源生效
+	ImageTemplate string `json:"imageTemplate,omitempty"`
 }
 
 // IsValid 检查代理项是否有效
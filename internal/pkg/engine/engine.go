@@ -0,0 +1,369 @@
+// Package engine 提供 Docker Engine API 客户端
+// 直接通过 unix socket 或 DOCKER_HOST 指定的 TCP 地址访问 dockerd，无需安装 docker 命令行
+package engine
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultHost 未设置 DOCKER_HOST 时使用的 dockerd 地址
+const DefaultHost = "unix:///var/run/docker.sock"
+
+// pingTimeout 探测 dockerd 时的超时时间
+const pingTimeout = 3 * time.Second
+
+// Client Docker Engine API 客户端
+type Client struct {
+	// HTTPClient 底层 HTTP 客户端
+	HTTPClient *http.Client
+
+	// baseURL API 地址前缀，unix socket 使用占位主机名
+	baseURL string
+}
+
+// Error Engine API 返回的错误
+type Error struct {
+	// StatusCode HTTP 状态码
+	StatusCode int
+
+	// Message dockerd 返回的错误信息
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Docker Engine API 返回 HTTP 状态码 %d", e.StatusCode)
+	}
+	return e.Message
+}
+
+// IsNotFound 判断错误是否表示镜像等对象不存在
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// AuthConfig 拉取镜像时通过 X-Registry-Auth 传递的凭据
+type AuthConfig struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	ServerAddress string `json:"serveraddress,omitempty"`
+}
+
+// EncodeAuth 将凭据编码为 X-Registry-Auth 请求头的值
+func EncodeAuth(auth AuthConfig) (string, error) {
+	data, err := json.Marshal(auth)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// JSONMessage 拉取镜像时 dockerd 返回的进度消息
+type JSONMessage struct {
+	// ID 层的短摘要或标签，整体状态消息为空
+	ID string `json:"id"`
+
+	// Status 状态，如 Downloading、Pull complete
+	Status string `json:"status"`
+
+	// ProgressDetail 字节级进度
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+
+	// Error 拉取失败时的错误信息
+	Error string `json:"error"`
+}
+
+// NewClient 按 DOCKER_HOST 创建客户端，未设置时使用默认的 unix socket
+// 支持 unix:// 与 tcp://（DOCKER_TLS_VERIFY 开启时使用 DOCKER_CERT_PATH 中的证书），
+// 其他协议（如 ssh://、npipe://）返回错误，调用方应改用 docker 命令行
+func NewClient() (*Client, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		if runtime.GOOS == "windows" {
+			return nil, fmt.Errorf("Windows 命名管道暂不支持")
+		}
+		host = DefaultHost
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 DOCKER_HOST: %s", host)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		return &Client{HTTPClient: &http.Client{Transport: transport}, baseURL: "http://docker"}, nil
+	case "tcp", "http", "https":
+		scheme := "http"
+		if u.Scheme == "https" || os.Getenv("DOCKER_TLS_VERIFY") != "" {
+			tlsConfig, err := loadTLSConfig()
+			if err != nil {
+				return nil, err
+			}
+			transport.TLSClientConfig = tlsConfig
+			scheme = "https"
+		}
+		return &Client{HTTPClient: &http.Client{Transport: transport}, baseURL: scheme + "://" + u.Host}, nil
+	default:
+		return nil, fmt.Errorf("不支持的 DOCKER_HOST 协议: %s", u.Scheme)
+	}
+}
+
+// loadTLSConfig 读取 DOCKER_CERT_PATH（默认 ~/.docker）中的 ca.pem、cert.pem 与 key.pem
+func loadTLSConfig() (*tls.Config, error) {
+	dir := os.Getenv("DOCKER_CERT_PATH")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("读取 Docker 客户端证书失败: %w", err)
+	}
+	ca, err := os.ReadFile(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("读取 Docker CA 证书失败: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("无效的 Docker CA 证书")
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: pool}, nil
+}
+
+// Available 创建客户端并探测 dockerd 是否可访问
+func Available() (*Client, error) {
+	client, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	if err := client.Ping(ctx); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// Ping 检查 dockerd 是否可访问
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.do(ctx, http.MethodGet, "/_ping", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// ServerPlatform 返回 dockerd 运行的平台，如 linux/amd64
+func (c *Client) ServerPlatform(ctx context.Context) (string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/version", nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var version struct {
+		Os   string `json:"Os"`
+		Arch string `json:"Arch"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return "", fmt.Errorf("解析 dockerd 版本信息失败: %w", err)
+	}
+	return version.Os + "/" + version.Arch, nil
+}
+
+// PullImage 拉取镜像，并将进度消息逐条交给 progress 处理
+// image: 镜像引用，未指定标签时拉取 latest
+// platform: 目标平台，为空时由 dockerd 选择
+// registryAuth: EncodeAuth 编码后的凭据，可以为空
+// progress: 进度回调，可以为 nil
+func (c *Client) PullImage(ctx context.Context, image, platform, registryAuth string, progress func(*JSONMessage)) error {
+	name, tag := splitImageTag(image)
+	if tag == "" {
+		// 不指定标签时 dockerd 会拉取全部标签
+		tag = "latest"
+	}
+
+	query := url.Values{}
+	query.Set("fromImage", name)
+	query.Set("tag", tag)
+	if platform != "" {
+		query.Set("platform", platform)
+	}
+
+	header := http.Header{}
+	if registryAuth != "" {
+		header.Set("X-Registry-Auth", registryAuth)
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/images/create?"+query.Encode(), header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 拉取过程中的错误通过消息流返回，HTTP 状态码仍为 200
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg JSONMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("读取拉取进度失败: %w", err)
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+		if progress != nil {
+			progress(&msg)
+		}
+	}
+}
+
+// TagImage 为镜像添加标签
+// source: 源镜像名称或 ID
+// target: 新的镜像名称，未指定标签时使用 latest
+func (c *Client) TagImage(ctx context.Context, source, target string) error {
+	repo, tag := splitImageTag(target)
+	if tag == "" {
+		tag = "latest"
+	}
+
+	query := url.Values{}
+	query.Set("repo", repo)
+	query.Set("tag", tag)
+
+	resp, err := c.do(ctx, http.MethodPost, "/images/"+source+"/tag?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// RemoveImage 删除镜像标签，镜像仍被其他标签引用时只会移除该标签
+func (c *Client) RemoveImage(ctx context.Context, name string) error {
+	resp, err := c.do(ctx, http.MethodDelete, "/images/"+name, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// InspectImage 查询本地镜像信息，返回 dockerd 的原始 JSON
+func (c *Client) InspectImage(ctx context.Context, name string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/images/"+name+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// ImageSummary 镜像列表中的单个镜像（仅包含需要的字段）
+type ImageSummary struct {
+	// ID 镜像 ID
+	ID string `json:"Id"`
+
+	// RepoTags 镜像的全部标签，没有标签时为空或 <none>:<none>
+	RepoTags []string `json:"RepoTags"`
+
+	// RepoDigests 镜像的仓库摘要，形如 name@sha256:...
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+// ListImages 列出本地的全部镜像（不含中间层）
+func (c *Client) ListImages(ctx context.Context) ([]ImageSummary, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/images/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var images []ImageSummary
+	if err := json.NewDecoder(resp.Body).Decode(&images); err != nil {
+		return nil, fmt.Errorf("解析镜像列表失败: %w", err)
+	}
+	return images, nil
+}
+
+// do 发送请求，非 2xx 响应转换为 *Error
+func (c *Client) do(ctx context.Context, method, path string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("访问 Docker Engine API 失败: %w", err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	var payload struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &payload) == nil && payload.Message != "" {
+		apiErr.Message = payload.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return nil, apiErr
+}
+
+// splitImageTag 将镜像引用拆分为名称与标签（或摘要）
+// 例如 nginx:1.25 -> nginx, 1.25；alpine@sha256:... -> alpine, sha256:...
+func splitImageTag(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, ""
+}
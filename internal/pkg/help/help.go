@@ -22,15 +22,67 @@ func PrintHelp() {
 	fmt.Println("    clone <repo>         克隆 GitHub 仓库")
 	fmt.Println("    pull                 拉取最新更改")
 	fmt.Println("    down <url> [file]    使用代理加速下载 GitHub Release 文件")
+	fmt.Println("    setup                写入 GitHub 的 insteadOf 规则，使其他工具也经代理访问")
+	fmt.Println("      --scope <scope>    git 配置范围 global|system|local（默认 global）")
+	fmt.Println("      --switch <id>      改用指定 ID 的代理")
+	fmt.Println("      --revert           撤销 cnfast 写入的规则")
+	fmt.Println("    cnfast::<url>        作为 git-remote-cnfast 远程助手使用，如 git clone cnfast::https://github.com/user/repo")
 	fmt.Println()
 	fmt.Println("  docker <command>       执行 Docker 命令并加速镜像拉取")
-	fmt.Println("    pull <image>         拉取 Docker 镜像（支持加速域名与自动 retag）")
-	fmt.Println("    push <image>         推送 Docker 镜像（使用加速域名）")
+	fmt.Println("    pull <image>...      拉取 Docker 镜像（支持加速域名与自动 retag）")
+	fmt.Println("      -f, --file <file>  从镜像列表文件批量拉取（每行一个镜像，支持 # 注释）")
+	fmt.Println("      -j, --parallel <n> 批量拉取的并发数（默认 3）")
+	fmt.Println("      --platform <p,...> 拉取一个或多个平台（如 linux/amd64,linux/arm64）；多个平台时各平台使用")
+	fmt.Println("                         平台后缀标签（如 nginx:1.25-linux-arm64），原始标签指向本机平台")
+	fmt.Println("      --platform-tag     只拉取一个平台时同样使用平台后缀标签")
+	fmt.Println("    push <image>         推送 Docker 镜像（代理支持时经加速域名推送，否则直接推送）")
 	fmt.Println("    build ...            构建镜像，保留原始行为")
+	fmt.Println("    run/create ...       本地缺少镜像时先加速拉取，然后原样执行 docker run/create")
+	fmt.Println("    buildx build ...     使用配置了镜像源加速的 cnfast 构建器（BuildKit）构建，支持多平台")
+	fmt.Println("    bundle create        加速拉取镜像并导出为离线镜像包（-f 镜像列表, -o 输出文件）")
+	fmt.Println("    bundle load <file>   在离线环境校验并导入镜像包")
+	fmt.Println("    lock [image...]      解析镜像摘要并写入 cnfast.lock（-f 镜像列表, -c compose 文件, -o 输出文件）")
+	fmt.Println("                         当前目录存在 cnfast.lock 时，pull 与 compose 会校验镜像摘要")
+	fmt.Println("    tags <image>         不拉取镜像，列出远程仓库的全部标签")
+	fmt.Println("    inspect-remote <image> 不拉取镜像，查看清单摘要、支持的平台、层数与压缩大小")
+	fmt.Println("    prune-accel          清理残留的加速域名标签，原始标签缺失时先恢复（--dry-run 仅列出）")
+	fmt.Println("    sync <src> <dest>    经加速域名将镜像直接复制到内部仓库，不经过本地 Docker")
+	fmt.Println("      -f, --file <file>  批量同步（每行 \"源镜像 目标镜像\"）")
+	fmt.Println("      --plain-http       使用 HTTP 访问目标仓库")
 	fmt.Println()
-	fmt.Println("  docker-compose         解析 docker-compose.yml 中的镜像并加速拉取")
+	fmt.Println("  docker-compose [service...] 解析 docker-compose.yml 中的镜像并加速拉取（指定 service 时不提示选择）")
 	fmt.Println("  docker compose         等价于 docker-compose，用于兼容 Docker 新版命令")
 	fmt.Println()
+	fmt.Println("  helm <command>         执行 Helm 命令并加速 chart 下载")
+	fmt.Println("    repo add <name> <url> 添加 chart 仓库（GitHub 托管的仓库经 git 代理加速）")
+	fmt.Println("    pull <chart>         下载 chart（oci:// 地址经镜像加速域名改写）")
+	fmt.Println("    install ...          安装 chart，安装前预拉取模板中引用的镜像（--no-prefetch 跳过）")
+	fmt.Println("    dependency update    更新 chart 依赖，临时改写 Chart.yaml 中的仓库地址")
+	fmt.Println()
+	fmt.Println("  k8s webhook            运行 Kubernetes 准入 webhook，将 Pod 镜像改写为加速地址")
+	fmt.Println("    --listen <addr>      监听地址（默认 :8443）")
+	fmt.Println("    --tls-cert/--tls-key 证书与私钥，不指定时使用 HTTP（仅用于本地测试）")
+	fmt.Println("    --namespace <ns,...> 开启加速的命名空间（* 表示全部）")
+	fmt.Println("    --label <key=value>  开启加速的 Pod 标签（默认 cnfast.io/accelerate=true）")
+	fmt.Println("    --exclude-registry <域名,...> 不改写的镜像源")
+	fmt.Println()
+	fmt.Println("  rewrite images -f <file> 输出镜像改写为加速地址的 YAML（保留注释与格式）")
+	fmt.Println("    -i, --in-place       直接修改文件")
+	fmt.Println("    --reverse            将加速地址还原为原始镜像名")
+	fmt.Println()
+	fmt.Println("  kind|k3d|minikube load <image>... 加速拉取镜像并导入本地集群节点（节点上已存在时跳过）")
+	fmt.Println("    --name <cluster>     集群名（k3d 也支持 -c，minikube 也支持 -p）")
+	fmt.Println("    -f, --file <file>    从镜像列表文件读取")
+	fmt.Println()
+	fmt.Println("  devcontainer prefetch  预拉取 devcontainer 配置引用的镜像与 feature")
+	fmt.Println("    -c, --config <file>  配置文件（默认 .devcontainer/devcontainer.json）")
+	fmt.Println("    -j, --parallel <n>   并发数")
+	fmt.Println()
+	fmt.Println("  shims install|uninstall 创建或删除 git、docker、docker-compose、curl 垫片（加入 PATH 后无需 cnfast 前缀）")
+	fmt.Println("    --dir <dir>          垫片目录（默认 ~/.cnfast/shims）")
+	fmt.Println()
+	fmt.Println("  completion <shell>     输出 bash|zsh|fish|powershell 补全脚本")
+	fmt.Println()
 	fmt.Println("  update                 检查并更新到最新版本")
 	fmt.Println()
 	fmt.Println("  -v, --version          显示版本信息")
@@ -39,15 +91,51 @@ func PrintHelp() {
 	fmt.Println("示例:")
 	fmt.Println("  # GitHub 仓库加速")
 	fmt.Println("  cnfast git clone https://github.com/user/repo.git")
+	fmt.Println("  cnfast git setup --scope global")
+	fmt.Println("  git clone cnfast::https://github.com/user/repo.git")
 	fmt.Println()
 	fmt.Println("  # Docker 镜像加速")
 	fmt.Println("  cnfast docker pull nginx:latest")
 	fmt.Println("  cnfast docker pull ubuntu:20.04")
+	fmt.Println("  cnfast docker pull alpine@sha256:<digest>")
+	fmt.Println("  cnfast docker pull nginx:latest redis:7 --file images.txt")
+	fmt.Println("  cnfast docker run --rm -it ghcr.io/org/tool:1.0 sh")
+	fmt.Println("  cnfast docker buildx build --platform linux/amd64,linux/arm64 -t org/app:1.0 --push .")
+	fmt.Println("  cnfast docker pull --platform linux/amd64,linux/arm64 nginx:1.25")
+	fmt.Println("  cnfast docker bundle create -f images.txt -o bundle.tar.gz")
+	fmt.Println("  cnfast docker bundle load bundle.tar.gz")
+	fmt.Println("  cnfast docker lock -c docker-compose.yml")
+	fmt.Println("  cnfast docker tags nginx")
+	fmt.Println("  cnfast docker inspect-remote ghcr.io/org/app:1.0")
+	fmt.Println("  cnfast docker prune-accel --dry-run")
+	fmt.Println("  cnfast docker sync nginx:1.25 harbor.example.com/mirror/nginx:1.25")
 	fmt.Println()
 	fmt.Println("  # docker-compose 镜像加速")
 	fmt.Println("  cnfast docker-compose")
 	fmt.Println("  cnfast docker compose")
 	fmt.Println()
+	fmt.Println("  # Helm chart 加速")
+	fmt.Println("  cnfast helm repo add prometheus https://prometheus-community.github.io/helm-charts")
+	fmt.Println("  cnfast helm pull oci://ghcr.io/org/charts/app --version 1.0.0")
+	fmt.Println("  cnfast helm install web oci://registry-1.docker.io/bitnamicharts/nginx")
+	fmt.Println("  cnfast helm dependency update ./mychart")
+	fmt.Println()
+	fmt.Println("  # 改写清单中的镜像地址")
+	fmt.Println("  cnfast rewrite images -f deploy.yaml > deploy.accel.yaml")
+	fmt.Println("  cnfast rewrite images -f deploy.accel.yaml --reverse")
+	fmt.Println()
+	fmt.Println("  # 导入本地 Kubernetes 集群")
+	fmt.Println("  cnfast kind load nginx:1.25 ghcr.io/org/app:1.0 --name dev")
+	fmt.Println()
+	fmt.Println("  # 预拉取开发容器依赖")
+	fmt.Println("  cnfast devcontainer prefetch")
+	fmt.Println()
+	fmt.Println("  # 安装命令垫片")
+	fmt.Println("  cnfast shims install && export PATH=\"$HOME/.cnfast/shims:$PATH\"")
+	fmt.Println()
+	fmt.Println("  # 启用 shell 补全")
+	fmt.Println("  source <(cnfast completion bash)")
+	fmt.Println()
 	fmt.Println("  # 更新 cnfast 自身")
 	fmt.Println("  cnfast update")
 	fmt.Println()
@@ -0,0 +1,156 @@
+// Package reference 提供 Docker 镜像引用的解析功能
+// 解析规则与 Docker 官方保持一致：
+//   - 第一段包含 "." 或 ":"，或者为 localhost 时视为 registry 域名
+//   - 未指定域名的镜像属于 Docker Hub，官方镜像补全 library/ 前缀
+//   - 支持 :tag 与 @sha256:... 摘要，二者可以同时存在
+package reference
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultDomain Docker Hub 的规范域名
+const DefaultDomain = "docker.io"
+
+// officialRepoPrefix Docker Hub 官方镜像的仓库前缀
+const officialRepoPrefix = "library/"
+
+var (
+	// pathComponentRegexp 仓库路径中单段名称的格式
+	pathComponentRegexp = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*$`)
+
+	// tagRegexp 标签格式
+	tagRegexp = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+
+	// digestRegexp 摘要格式
+	digestRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+
+	// domainRegexp registry 域名格式（可带端口）
+	domainRegexp = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9.-]*[a-zA-Z0-9])?(?::[0-9]+)?$`)
+)
+
+// Reference 表示解析后的镜像引用
+type Reference struct {
+	// Domain registry 域名，Docker Hub 统一为 docker.io
+	Domain string
+
+	// Path 仓库路径，Docker Hub 官方镜像包含 library/ 前缀
+	Path string
+
+	// Tag 标签，未指定时为空
+	Tag string
+
+	// Digest 摘要，如 sha256:...，未指定时为空
+	Digest string
+}
+
+// Parse 解析镜像引用
+// raw: 原始镜像引用，如 nginx、myregistry.local:5000/app:1.0、alpine@sha256:...
+func Parse(raw string) (*Reference, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("镜像引用不能为空")
+	}
+
+	ref := &Reference{}
+	remainder := raw
+
+	// 1. 拆分摘要
+	if idx := strings.Index(remainder, "@"); idx >= 0 {
+		ref.Digest = remainder[idx+1:]
+		remainder = remainder[:idx]
+		if !digestRegexp.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("无效的镜像摘要: %s", ref.Digest)
+		}
+	}
+
+	// 2. 拆分标签（最后一个 ":" 位于最后一个 "/" 之后时才是标签）
+	if idx := strings.LastIndex(remainder, ":"); idx > strings.LastIndex(remainder, "/") {
+		ref.Tag = remainder[idx+1:]
+		remainder = remainder[:idx]
+		if !tagRegexp.MatchString(ref.Tag) {
+			return nil, fmt.Errorf("无效的镜像标签: %s", ref.Tag)
+		}
+	}
+
+	// 3. 识别域名
+	ref.Domain, ref.Path = splitDomain(remainder)
+	if !domainRegexp.MatchString(ref.Domain) {
+		return nil, fmt.Errorf("无效的 registry 域名: %s", ref.Domain)
+	}
+
+	// 4. 校验仓库路径
+	if ref.Path == "" {
+		return nil, fmt.Errorf("无效的镜像引用: %s", raw)
+	}
+	for _, component := range strings.Split(ref.Path, "/") {
+		if !pathComponentRegexp.MatchString(component) {
+			return nil, fmt.Errorf("无效的镜像名称: %s", raw)
+		}
+	}
+
+	return ref, nil
+}
+
+// splitDomain 将镜像名称拆分为域名和仓库路径
+func splitDomain(name string) (string, string) {
+	domain, path := DefaultDomain, name
+
+	if idx := strings.Index(name, "/"); idx >= 0 {
+		first := name[:idx]
+		if strings.ContainsAny(first, ".:") || first == "localhost" || strings.ToLower(first) != first {
+			domain, path = first, name[idx+1:]
+		}
+	}
+
+	if domain == "index.docker.io" {
+		domain = DefaultDomain
+	}
+	if domain == DefaultDomain && !strings.Contains(path, "/") {
+		path = officialRepoPrefix + path
+	}
+	return domain, path
+}
+
+// IsDockerHub 判断镜像是否属于 Docker Hub
+func (r *Reference) IsDockerHub() bool {
+	return r.Domain == DefaultDomain
+}
+
+// Name 返回完整的仓库名称，如 docker.io/library/nginx
+func (r *Reference) Name() string {
+	return r.Domain + "/" + r.Path
+}
+
+// FamiliarName 返回 Docker 命令行中常用的简写名称
+// 例如 docker.io/library/nginx -> nginx，docker.io/user/app -> user/app
+func (r *Reference) FamiliarName() string {
+	if !r.IsDockerHub() {
+		return r.Name()
+	}
+	return strings.TrimPrefix(r.Path, officialRepoPrefix)
+}
+
+// Suffix 返回标签与摘要部分，如 ":1.0"、"@sha256:..." 或 ":1.0@sha256:..."
+func (r *Reference) Suffix() string {
+	suffix := ""
+	if r.Tag != "" {
+		suffix += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		suffix += "@" + r.Digest
+	}
+	return suffix
+}
+
+// String 返回完整的规范化引用
+func (r *Reference) String() string {
+	return r.Name() + r.Suffix()
+}
+
+// WithDomain 返回替换域名（或域名与路径前缀）后的引用
+// domain: 新的域名，可以带路径前缀，如 docker.example.com/ghcr
+func (r *Reference) WithDomain(domain string) string {
+	return strings.TrimRight(domain, "/") + "/" + r.Path + r.Suffix()
+}
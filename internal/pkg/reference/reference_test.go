@@ -0,0 +1,92 @@
+package reference
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		raw    string
+		domain string
+		path   string
+		tag    string
+		digest string
+	}{
+		{"nginx", "docker.io", "library/nginx", "", ""},
+		{"nginx:1.25", "docker.io", "library/nginx", "1.25", ""},
+		{"bitnami/redis:7", "docker.io", "bitnami/redis", "7", ""},
+		{"docker.io/nginx", "docker.io", "library/nginx", "", ""},
+		{"index.docker.io/library/nginx:latest", "docker.io", "library/nginx", "latest", ""},
+		{"ghcr.io/owner/app:v1", "ghcr.io", "owner/app", "v1", ""},
+		{"myregistry.local:5000/app:1.0", "myregistry.local:5000", "app", "1.0", ""},
+		{"myregistry.local:5000/app", "myregistry.local:5000", "app", "", ""},
+		{"localhost/app", "localhost", "app", "", ""},
+		{"localhost:5000/team/app:dev", "localhost:5000", "team/app", "dev", ""},
+		{"harbor.corp.com/team/sub/app:2", "harbor.corp.com", "team/sub/app", "2", ""},
+		{"alpine@" + digest, "docker.io", "library/alpine", "", digest},
+		{"nginx:1.25@" + digest, "docker.io", "library/nginx", "1.25", digest},
+		{"registry.k8s.io/pause:3.9", "registry.k8s.io", "pause", "3.9", ""},
+	}
+
+	for _, tt := range tests {
+		ref, err := Parse(tt.raw)
+		if err != nil {
+			t.Errorf("Parse(%q) 返回错误: %v", tt.raw, err)
+			continue
+		}
+		if ref.Domain != tt.domain || ref.Path != tt.path || ref.Tag != tt.tag || ref.Digest != tt.digest {
+			t.Errorf("Parse(%q) = {%s %s %s %s}, 期望 {%s %s %s %s}",
+				tt.raw, ref.Domain, ref.Path, ref.Tag, ref.Digest, tt.domain, tt.path, tt.tag, tt.digest)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"Nginx",
+		"nginx:",
+		"nginx@sha256:short",
+		"registry.example.com/",
+		"app/-bad",
+		"nginx:bad tag",
+	}
+
+	for _, raw := range tests {
+		if ref, err := Parse(raw); err == nil {
+			t.Errorf("Parse(%q) = %+v, 期望返回错误", raw, ref)
+		}
+	}
+}
+
+func TestReferenceFormat(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		raw      string
+		familiar string
+		str      string
+		accel    string
+	}{
+		{"nginx:1.25", "nginx", "docker.io/library/nginx:1.25", "accel.example.com/library/nginx:1.25"},
+		{"user/app", "user/app", "docker.io/user/app", "accel.example.com/user/app"},
+		{"ghcr.io/owner/app@" + digest, "ghcr.io/owner/app", "ghcr.io/owner/app@" + digest, "accel.example.com/owner/app@" + digest},
+		{"localhost:5000/app:1", "localhost:5000/app", "localhost:5000/app:1", "accel.example.com/app:1"},
+	}
+
+	for _, tt := range tests {
+		ref, err := Parse(tt.raw)
+		if err != nil {
+			t.Fatalf("Parse(%q) 返回错误: %v", tt.raw, err)
+		}
+		if got := ref.FamiliarName(); got != tt.familiar {
+			t.Errorf("%q FamiliarName() = %q, 期望 %q", tt.raw, got, tt.familiar)
+		}
+		if got := ref.String(); got != tt.str {
+			t.Errorf("%q String() = %q, 期望 %q", tt.raw, got, tt.str)
+		}
+		if got := ref.WithDomain("accel.example.com/"); got != tt.accel {
+			t.Errorf("%q WithDomain() = %q, 期望 %q", tt.raw, got, tt.accel)
+		}
+	}
+}
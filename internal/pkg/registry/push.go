@@ -0,0 +1,156 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// BlobExists 判断仓库中是否已存在指定 blob
+// host: registry 主机
+// repo: 仓库路径
+// digest: blob 摘要
+func (c *Client) BlobExists(ctx context.Context, host, repo, digest string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.endpoint(host, fmt.Sprintf("/v2/%s/blobs/%s", repo, digest)), nil)
+	if err != nil {
+		return false, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	resp, err := c.Do(req, repo, "pull,push")
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, statusError(resp, fmt.Sprintf("查询 %s", digest))
+	}
+}
+
+// OpenBlob 打开 blob 的下载流，调用方负责关闭
+// 返回: 内容流、内容大小（未知时为 -1）、错误
+func (c *Client) OpenBlob(ctx context.Context, host, repo, digest string) (io.ReadCloser, int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(host, fmt.Sprintf("/v2/%s/blobs/%s", repo, digest)), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	resp, err := c.Do(req, repo, "pull")
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		err := statusError(resp, fmt.Sprintf("下载 %s", digest))
+		resp.Body.Close()
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// PushAllowed 判断当前凭据能否向仓库推送
+// 通过发起上传检查权限，发起成功的上传会立即取消；registry 返回 401/403 时为 false
+func (c *Client) PushAllowed(ctx context.Context, host, repo string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(host, fmt.Sprintf("/v2/%s/blobs/uploads/", repo)), nil)
+	if err != nil {
+		return false, fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := c.Do(req, repo, "pull,push")
+	if err != nil {
+		if IsAuthError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		if location, err := req.URL.Parse(resp.Header.Get("Location")); err == nil && resp.Header.Get("Location") != "" {
+			if cancel, err := http.NewRequestWithContext(ctx, http.MethodDelete, location.String(), nil); err == nil {
+				if resp, err := c.Do(cancel, repo, "pull,push"); err == nil {
+					resp.Body.Close()
+				}
+			}
+		}
+		return true, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false, nil
+	default:
+		return false, statusError(resp, "检查推送权限")
+	}
+}
+
+// PushBlob 以单次上传的方式推送 blob
+// registry 会按摘要校验上传的内容；内容以流的方式发送，无法在认证后重发，
+// 因此先由发起上传的请求（无请求体）完成认证，上传时复用同一 scope 的令牌
+// size: 内容大小，未知时为 -1
+func (c *Client) PushBlob(ctx context.Context, host, repo, digest string, size int64, content io.Reader) error {
+	// 1. 发起上传，获取上传地址
+	start, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint(host, fmt.Sprintf("/v2/%s/blobs/uploads/", repo)), nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	resp, err := c.Do(start, repo, "pull,push")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		defer resp.Body.Close()
+		return statusError(resp, fmt.Sprintf("发起上传 %s", digest))
+	}
+	resp.Body.Close()
+
+	location, err := start.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("registry 未返回有效的上传地址")
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	// 2. 上传内容并完成
+	put, err := http.NewRequestWithContext(ctx, http.MethodPut, location.String(), content)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	put.ContentLength = size
+	put.Header.Set("Content-Type", "application/octet-stream")
+
+	resp, err = c.Do(put, repo, "pull,push")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return statusError(resp, fmt.Sprintf("上传 %s", digest))
+	}
+	return nil
+}
+
+// PutManifest 推送清单
+// ref: 标签或摘要
+func (c *Client) PutManifest(ctx context.Context, host, repo, ref string, manifest *Manifest) error {
+	// bytes.Reader 可以重复读取，认证后重试时能再次发送
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, c.endpoint(host, fmt.Sprintf("/v2/%s/manifests/%s", repo, ref)), bytes.NewReader(manifest.Raw))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", manifest.MediaType)
+
+	resp, err := c.Do(req, repo, "pull,push")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return statusError(resp, fmt.Sprintf("推送清单 %s", ref))
+	}
+	return nil
+}
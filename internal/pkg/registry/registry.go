@@ -0,0 +1,560 @@
+// Package registry 提供 Docker Registry HTTP API v2 客户端
+// 支持匿名访问与 Bearer Token / Basic 认证
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 清单媒体类型
+const (
+	// MediaTypeDockerManifest Docker 镜像清单
+	MediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// MediaTypeDockerManifestList Docker 多平台清单列表
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	// MediaTypeOCIManifest OCI 镜像清单
+	MediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+
+	// MediaTypeOCIIndex OCI 多平台索引
+	MediaTypeOCIIndex = "application/vnd.oci.image.index.v1+json"
+)
+
+// manifestAcceptTypes 请求清单时接受的媒体类型
+var manifestAcceptTypes = []string{
+	MediaTypeOCIIndex,
+	MediaTypeDockerManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeDockerManifest,
+}
+
+// AuthFunc 返回访问指定主机使用的用户名和密码，无凭据时返回空字符串
+type AuthFunc func(host string) (username, secret string)
+
+// Client Registry v2 API 客户端
+type Client struct {
+	// HTTPClient 底层 HTTP 客户端
+	HTTPClient *http.Client
+
+	// auth 凭据查询函数，可以为 nil
+	auth AuthFunc
+
+	// tokens 已获取的 Bearer Token，按 主机+scope 缓存
+	tokens   map[string]string
+	tokensMu sync.Mutex
+
+	// plainHTTP 使用 HTTP 而非 HTTPS 访问的主机
+	plainHTTP map[string]bool
+}
+
+// NewClient 创建 Registry 客户端
+// auth: 凭据查询函数，为 nil 时匿名访问
+func NewClient(auth AuthFunc) *Client {
+	// 只限制等待响应头的时间，下载大的 blob 时不受整体超时限制
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 60 * time.Second
+
+	return &Client{
+		HTTPClient: &http.Client{Transport: transport},
+		auth:       auth,
+		tokens:     make(map[string]string),
+		plainHTTP:  make(map[string]bool),
+	}
+}
+
+// UsePlainHTTP 使用 HTTP 访问指定主机（用于未配置证书的内部仓库）
+func (c *Client) UsePlainHTTP(host string) {
+	c.plainHTTP[host] = true
+}
+
+// endpoint 生成 API 地址
+// path: 以 /v2/ 开头的路径
+func (c *Client) endpoint(host, path string) string {
+	scheme := "https"
+	if c.plainHTTP[host] {
+		scheme = "http"
+	}
+	return scheme + "://" + host + path
+}
+
+// APIHost 返回 registry 实际的 API 主机
+// Docker Hub 的镜像名使用 docker.io，API 地址为 registry-1.docker.io
+func APIHost(domain string) string {
+	if domain == "docker.io" || domain == "index.docker.io" {
+		return "registry-1.docker.io"
+	}
+	return domain
+}
+
+// Descriptor 内容描述符
+type Descriptor struct {
+	// MediaType 媒体类型
+	MediaType string `json:"mediaType"`
+
+	// Digest 内容摘要
+	Digest string `json:"digest"`
+
+	// Size 内容大小（字节）
+	Size int64 `json:"size"`
+
+	// Platform 平台信息（仅清单列表中的条目有）
+	Platform *Platform `json:"platform,omitempty"`
+
+	// URLs 外部下载地址（不可分发的层，如 Windows 基础镜像层）
+	URLs []string `json:"urls,omitempty"`
+
+	// Annotations 注解，如 org.opencontainers.image.title
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Platform 平台信息
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String 返回 os/arch[/variant] 形式的平台描述
+func (p *Platform) String() string {
+	if p == nil {
+		return ""
+	}
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Manifest 镜像清单或清单列表
+type Manifest struct {
+	// SchemaVersion 清单格式版本
+	SchemaVersion int `json:"schemaVersion"`
+
+	// MediaType 媒体类型
+	MediaType string `json:"mediaType,omitempty"`
+
+	// Config 镜像配置（单平台清单）
+	Config *Descriptor `json:"config,omitempty"`
+
+	// Layers 镜像层（单平台清单）
+	Layers []Descriptor `json:"layers,omitempty"`
+
+	// Manifests 各平台清单（清单列表）
+	Manifests []Descriptor `json:"manifests,omitempty"`
+
+	// Raw 原始清单内容
+	Raw []byte `json:"-"`
+
+	// Digest 清单摘要
+	Digest string `json:"-"`
+}
+
+// TotalSize 返回单平台清单中各层压缩后的总大小（字节）
+func (m *Manifest) TotalSize() int64 {
+	var size int64
+	for _, layer := range m.Layers {
+		size += layer.Size
+	}
+	return size
+}
+
+// IsIndex 判断是否为多平台清单列表
+func (m *Manifest) IsIndex() bool {
+	return m.MediaType == MediaTypeDockerManifestList || m.MediaType == MediaTypeOCIIndex ||
+		(m.MediaType == "" && len(m.Manifests) > 0)
+}
+
+// ResolveDigest 查询清单摘要（不下载清单内容）
+// host: registry 主机
+// repo: 仓库路径
+// ref: 标签或摘要
+func (c *Client) ResolveDigest(ctx context.Context, host, repo, ref string) (string, error) {
+	resp, err := c.manifestRequest(ctx, http.MethodHead, host, repo, ref)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+
+	// 部分 registry 的 HEAD 响应不返回摘要，退回 GET 并自行计算
+	manifest, err := c.GetManifest(ctx, host, repo, ref)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Digest, nil
+}
+
+// GetManifest 获取清单
+// host: registry 主机
+// repo: 仓库路径
+// ref: 标签或摘要
+func (c *Client) GetManifest(ctx context.Context, host, repo, ref string) (*Manifest, error) {
+	resp, err := c.manifestRequest(ctx, http.MethodGet, host, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取清单失败: %w", err)
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(raw, manifest); err != nil {
+		return nil, fmt.Errorf("解析清单失败: %w", err)
+	}
+	if manifest.MediaType == "" {
+		manifest.MediaType = strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	}
+	// 摘要按实际内容计算，不信任响应头
+	manifest.Raw = raw
+	manifest.Digest = Digest(raw)
+
+	// 按摘要获取时校验内容，防止返回被篡改的清单
+	if strings.HasPrefix(ref, "sha256:") && manifest.Digest != ref {
+		return nil, fmt.Errorf("清单 %s 的内容与摘要不一致", ref)
+	}
+	return manifest, nil
+}
+
+// ListTags 列出仓库的全部标签，自动处理分页
+// host: registry 主机
+// repo: 仓库路径
+func (c *Client) ListTags(ctx context.Context, host, repo string) ([]string, error) {
+	endpoint := c.endpoint(host, fmt.Sprintf("/v2/%s/tags/list?n=1000", repo))
+	var tags []string
+
+	for endpoint != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("创建请求失败: %w", err)
+		}
+
+		resp, err := c.Do(req, repo, "pull")
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := statusError(resp, fmt.Sprintf("获取标签列表 %s/%s", host, repo))
+			resp.Body.Close()
+			return nil, err
+		}
+
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析标签列表失败: %w", err)
+		}
+		tags = append(tags, page.Tags...)
+
+		endpoint, err = nextPageURL(req.URL, resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return tags, nil
+}
+
+// nextPageURL 解析 Link 头中 rel="next" 的地址，没有下一页时返回空字符串
+// 例如: </v2/library/nginx/tags/list?last=1.25&n=1000>; rel="next"
+func nextPageURL(current *url.URL, link string) (string, error) {
+	if link == "" || !strings.Contains(link, `rel="next"`) {
+		return "", nil
+	}
+	start := strings.Index(link, "<")
+	end := strings.Index(link, ">")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("无效的分页信息: %s", link)
+	}
+
+	next, err := current.Parse(link[start+1 : end])
+	if err != nil {
+		return "", fmt.Errorf("无效的分页地址: %w", err)
+	}
+	return next.String(), nil
+}
+
+// GetBlobJSON 下载 JSON 格式的 blob（如镜像配置）并解析到 v
+// host: registry 主机
+// repo: 仓库路径
+// digest: blob 摘要
+func (c *Client) GetBlobJSON(ctx context.Context, host, repo, digest string, v interface{}) error {
+	endpoint := c.endpoint(host, fmt.Sprintf("/v2/%s/blobs/%s", repo, digest))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	resp, err := c.Do(req, repo, "pull")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp, fmt.Sprintf("下载 %s", digest))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("解析 %s 失败: %w", digest, err)
+	}
+	return nil
+}
+
+// Digest 计算内容的 sha256 摘要
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// manifestRequest 发送清单请求
+func (c *Client) manifestRequest(ctx context.Context, method, host, repo, ref string) (*http.Response, error) {
+	endpoint := c.endpoint(host, fmt.Sprintf("/v2/%s/manifests/%s", repo, ref))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", strings.Join(manifestAcceptTypes, ", "))
+
+	resp, err := c.Do(req, repo, "pull")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, statusError(resp, fmt.Sprintf("获取清单 %s/%s:%s", host, repo, ref))
+	}
+	return resp, nil
+}
+
+// Do 发送请求，遇到 401 时按 WWW-Authenticate 完成认证后重试
+// 请求体不能重复读取（GetBody 为空）时不会重试，而是返回错误；
+// 这类请求应先通过其他请求取得相同 scope 的令牌
+// repo: 仓库路径，用于生成 scope
+// actions: 需要的权限，如 "pull" 或 "pull,push"
+func (c *Client) Do(req *http.Request, repo, actions string) (*http.Response, error) {
+	host := req.URL.Host
+	scope := fmt.Sprintf("repository:%s:%s", repo, actions)
+	cacheKey := host + "|" + scope
+
+	c.tokensMu.Lock()
+	token := c.tokens[cacheKey]
+	c.tokensMu.Unlock()
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求 %s 失败: %w", host, err)
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	// 请求体已被读取，无法在认证后重新发送
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return nil, fmt.Errorf("%s 要求重新认证，但请求内容无法重复发送", host)
+	}
+
+	authorization, err := c.authorize(req.Context(), host, challenge, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	c.tokensMu.Lock()
+	c.tokens[cacheKey] = authorization
+	c.tokensMu.Unlock()
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	retry.Header.Set("Authorization", authorization)
+
+	resp, err = c.HTTPClient.Do(retry)
+	if err != nil {
+		return nil, fmt.Errorf("请求 %s 失败: %w", host, err)
+	}
+	return resp, nil
+}
+
+// authorize 根据认证质询生成 Authorization 头
+func (c *Client) authorize(ctx context.Context, host, challenge, scope string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	username, secret := "", ""
+	if c.auth != nil {
+		username, secret = c.auth(host)
+	}
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" && secret == "" {
+			return "", &StatusError{Action: host + " 认证", StatusCode: http.StatusUnauthorized, Message: "需要认证，但未找到凭据"}
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(username, secret)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := c.fetchToken(ctx, params, scope, username, secret)
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	default:
+		return "", fmt.Errorf("%s 使用了不支持的认证方式: %s", host, challenge)
+	}
+}
+
+// fetchToken 向认证服务获取 Bearer Token
+func (c *Client) fetchToken(ctx context.Context, params map[string]string, scope, username, secret string) (string, error) {
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("认证质询中缺少 realm")
+	}
+
+	query := url.Values{}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("创建认证请求失败: %w", err)
+	}
+	if username != "" || secret != "" {
+		req.SetBasicAuth(username, secret)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("获取访问令牌失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp, "获取访问令牌")
+	}
+
+	var result struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("解析访问令牌失败: %w", err)
+	}
+	if result.Token != "" {
+		return result.Token, nil
+	}
+	if result.AccessToken != "" {
+		return result.AccessToken, nil
+	}
+	return "", fmt.Errorf("认证服务未返回访问令牌")
+}
+
+// parseChallenge 解析 WWW-Authenticate 头
+// 例如: Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+	header = strings.TrimSpace(header)
+
+	idx := strings.Index(header, " ")
+	if idx < 0 {
+		return header, params
+	}
+	scheme := header[:idx]
+	rest := header[idx+1:]
+
+	for rest != "" {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				value, rest = rest, ""
+			} else {
+				value, rest = rest[:end], rest[end+1:]
+			}
+		}
+		params[key] = value
+	}
+
+	return scheme, params
+}
+
+// statusError 根据非预期的响应状态生成错误
+func statusError(resp *http.Response, action string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return &StatusError{Action: action, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
+
+// StatusError registry 返回非预期状态码时的错误
+type StatusError struct {
+	// Action 失败的操作
+	Action string
+
+	// StatusCode HTTP 状态码
+	StatusCode int
+
+	// Message 响应内容（截断）
+	Message string
+}
+
+// Error 实现 error 接口
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s失败，HTTP 状态码: %d", e.Action, e.StatusCode)
+	}
+	return fmt.Sprintf("%s失败，HTTP 状态码: %d, %s", e.Action, e.StatusCode, e.Message)
+}
+
+// IsAuthError 判断错误是否表示需要认证或无权访问（HTTP 401/403）
+func IsAuthError(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.StatusCode == http.StatusUnauthorized || statusErr.StatusCode == http.StatusForbidden
+}
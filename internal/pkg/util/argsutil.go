@@ -0,0 +1,67 @@
+package util
+
+import "strings"
+
+// ExtractFlagValue 从参数列表中提取带值的选项
+// 支持 "--name value" 与 "--name=value" 两种写法
+// args: 原始参数列表
+// names: 选项名称（可以包含短选项，如 "-f", "--file"）
+// 返回: 选项值、去除该选项后的剩余参数、是否找到该选项
+func ExtractFlagValue(args []string, names ...string) (string, []string, bool) {
+	rest := make([]string, 0, len(args))
+	value := ""
+	found := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		matched := false
+		for _, name := range names {
+			if arg == name {
+				if i+1 < len(args) {
+					value = args[i+1]
+					i++
+				}
+				matched = true
+				break
+			}
+			if strings.HasPrefix(arg, name+"=") {
+				value = strings.TrimPrefix(arg, name+"=")
+				matched = true
+				break
+			}
+		}
+		if matched {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	return value, rest, found
+}
+
+// ExtractBoolFlag 从参数列表中提取布尔选项
+// args: 原始参数列表
+// names: 选项名称
+// 返回: 是否存在该选项、去除该选项后的剩余参数
+func ExtractBoolFlag(args []string, names ...string) (bool, []string) {
+	rest := make([]string, 0, len(args))
+	found := false
+
+	for _, arg := range args {
+		matched := false
+		for _, name := range names {
+			if arg == name {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			found = true
+			continue
+		}
+		rest = append(rest, arg)
+	}
+
+	return found, rest
+}
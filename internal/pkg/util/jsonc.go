@@ -0,0 +1,61 @@
+package util
+
+// JSONCToJSON 将 JSONC（带注释的 JSON，如 devcontainer.json）转换为标准 JSON
+// 去除 // 与 /* */ 注释以及对象和数组末尾多余的逗号，字符串内容保持不变
+func JSONCToJSON(data []byte) []byte {
+	output := make([]byte, 0, len(data))
+	// pendingComma 暂存的逗号位置，遇到 } 或 ] 时丢弃
+	pendingComma := -1
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+
+		switch {
+		case c == '"':
+			// 复制完整的字符串，处理转义
+			start := i
+			for i++; i < len(data); i++ {
+				if data[i] == '\\' {
+					i++
+					continue
+				}
+				if data[i] == '"' {
+					break
+				}
+			}
+			if i >= len(data) {
+				i = len(data) - 1
+			}
+			pendingComma = -1
+			output = append(output, data[start:i+1]...)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				output = append(output, '\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == ',':
+			pendingComma = len(output)
+			output = append(output, c)
+		case c == '}' || c == ']':
+			if pendingComma >= 0 {
+				output = append(output[:pendingComma], output[pendingComma+1:]...)
+				pendingComma = -1
+			}
+			output = append(output, c)
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			output = append(output, c)
+		default:
+			pendingComma = -1
+			output = append(output, c)
+		}
+	}
+	return output
+}
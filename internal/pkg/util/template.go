@@ -0,0 +1,41 @@
+package util
+
+import "strings"
+
+// RenderTemplate 渲染 {name} 形式的占位符模板
+// 未提供值的占位符保持原样，便于分多次渲染
+// tpl: 模板，如 "{proxy}/{host}/{path}"
+// vars: 占位符名称到值的映射
+func RenderTemplate(tpl string, vars map[string]string) string {
+	var builder strings.Builder
+	builder.Grow(len(tpl))
+
+	for {
+		start := strings.Index(tpl, "{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(tpl[start:], "}")
+		if end < 0 {
+			break
+		}
+		end += start
+
+		builder.WriteString(tpl[:start])
+		name := tpl[start+1 : end]
+		if value, ok := vars[name]; ok {
+			builder.WriteString(value)
+		} else {
+			builder.WriteString(tpl[start : end+1])
+		}
+		tpl = tpl[end+1:]
+	}
+
+	builder.WriteString(tpl)
+	return builder.String()
+}
+
+// HasPlaceholder 判断模板中是否包含指定占位符
+func HasPlaceholder(tpl, name string) bool {
+	return strings.Contains(tpl, "{"+name+"}")
+}
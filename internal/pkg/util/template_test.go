@@ -0,0 +1,63 @@
+package util
+
+import "testing"
+
+func TestRenderTemplate(t *testing.T) {
+	vars := map[string]string{
+		"proxy": "https://proxy.example.com",
+		"host":  "github.com",
+		"path":  "owner/repo.git",
+		"url":   "https://github.com/owner/repo.git",
+	}
+
+	tests := []struct {
+		tpl  string
+		want string
+	}{
+		{"{proxy}/{url}", "https://proxy.example.com/https://github.com/owner/repo.git"},
+		{"{proxy}/{host}/{path}", "https://proxy.example.com/github.com/owner/repo.git"},
+		{"{proxy}/gh/{path}", "https://proxy.example.com/gh/owner/repo.git"},
+		{"ghcr.{proxy}", "ghcr.https://proxy.example.com"},
+		{"{proxy}/{unknown}/{path}", "https://proxy.example.com/{unknown}/owner/repo.git"},
+		{"no placeholder", "no placeholder"},
+		{"{proxy", "{proxy"},
+		{"{path}}{", "owner/repo.git}{"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := RenderTemplate(tt.tpl, vars); got != tt.want {
+			t.Errorf("RenderTemplate(%q) = %q, 期望 %q", tt.tpl, got, tt.want)
+		}
+	}
+}
+
+func TestRenderTemplateKeepsMissing(t *testing.T) {
+	// 未提供的占位符保持原样，可以分两次渲染
+	first := RenderTemplate("{proxy}/{host}/{path}", map[string]string{"proxy": "accel.example.com", "host": "ghcr.io"})
+	if first != "accel.example.com/ghcr.io/{path}" {
+		t.Fatalf("第一次渲染结果 %q", first)
+	}
+	if got := RenderTemplate(first, map[string]string{"path": "owner/app"}); got != "accel.example.com/ghcr.io/owner/app" {
+		t.Errorf("第二次渲染结果 %q", got)
+	}
+}
+
+func TestHasPlaceholder(t *testing.T) {
+	tests := []struct {
+		tpl  string
+		name string
+		want bool
+	}{
+		{"{proxy}/{host}/{path}", "path", true},
+		{"{proxy}/{host}/{path}", "url", false},
+		{"quay.{proxy}", "proxy", true},
+		{"path", "path", false},
+	}
+
+	for _, tt := range tests {
+		if got := HasPlaceholder(tt.tpl, tt.name); got != tt.want {
+			t.Errorf("HasPlaceholder(%q, %q) = %v, 期望 %v", tt.tpl, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+// Package services 包含将加速镜像导入本地 Kubernetes 集群的逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/util"
+
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// localCluster 本地 Kubernetes 集群工具
+type localCluster struct {
+	// Tool 工具名称: kind、k3d 或 minikube
+	Tool string
+
+	// DefaultName 未指定集群名时使用的名称
+	DefaultName string
+
+	// listNodes 列出集群的节点
+	listNodes func(cluster string) ([]string, error)
+
+	// crictlCmd 在节点上执行 crictl 的命令
+	crictlCmd func(cluster, node string, args ...string) *exec.Cmd
+
+	// loadCmd 将本地镜像导入集群全部节点的命令
+	loadCmd func(cluster, image string) *exec.Cmd
+}
+
+// localClusters 支持的本地集群工具
+var localClusters = map[string]*localCluster{
+	"kind": {
+		Tool:        "kind",
+		DefaultName: "kind",
+		listNodes: func(cluster string) ([]string, error) {
+			return commandLines(exec.Command("kind", "get", "nodes", "--name", cluster))
+		},
+		crictlCmd: func(cluster, node string, args ...string) *exec.Cmd {
+			return exec.Command("docker", append([]string{"exec", node, "crictl"}, args...)...)
+		},
+		loadCmd: func(cluster, image string) *exec.Cmd {
+			return exec.Command("kind", "load", "docker-image", image, "--name", cluster)
+		},
+	},
+	"k3d": {
+		Tool:        "k3d",
+		DefaultName: "k3s-default",
+		listNodes: func(cluster string) ([]string, error) {
+			nodes, err := commandLines(exec.Command("docker", "ps",
+				"--filter", "label=k3d.cluster="+cluster,
+				"--filter", "label=k3d.role=server",
+				"--format", "{{.Names}}"))
+			if err != nil {
+				return nil, err
+			}
+			agents, err := commandLines(exec.Command("docker", "ps",
+				"--filter", "label=k3d.cluster="+cluster,
+				"--filter", "label=k3d.role=agent",
+				"--format", "{{.Names}}"))
+			return append(nodes, agents...), err
+		},
+		crictlCmd: func(cluster, node string, args ...string) *exec.Cmd {
+			return exec.Command("docker", append([]string{"exec", node, "crictl"}, args...)...)
+		},
+		loadCmd: func(cluster, image string) *exec.Cmd {
+			return exec.Command("k3d", "image", "import", image, "--cluster", cluster)
+		},
+	},
+	"minikube": {
+		Tool:        "minikube",
+		DefaultName: "minikube",
+		listNodes: func(cluster string) ([]string, error) {
+			lines, err := commandLines(exec.Command("minikube", "node", "list", "--profile", cluster))
+			var nodes []string
+			for _, line := range lines {
+				if fields := strings.Fields(line); len(fields) > 0 {
+					nodes = append(nodes, fields[0])
+				}
+			}
+			return nodes, err
+		},
+		crictlCmd: func(cluster, node string, args ...string) *exec.Cmd {
+			sshArgs := []string{"ssh", "--profile", cluster, "--node", node, "--", "sudo", "crictl"}
+			return exec.Command("minikube", append(sshArgs, args...)...)
+		},
+		loadCmd: func(cluster, image string) *exec.Cmd {
+			return exec.Command("minikube", "image", "load", image, "--profile", cluster)
+		},
+	},
+}
+
+// crictlImageList crictl images -o json 的输出（只包含需要的字段）
+type crictlImageList struct {
+	Images []struct {
+		ID       string   `json:"id"`
+		RepoTags []string `json:"repoTags"`
+	} `json:"images"`
+}
+
+// LocalClusterLoad 处理 cnfast kind|k3d|minikube load 命令
+// 经加速域名拉取镜像并重新打标签，然后导入集群节点；节点上已有相同镜像时跳过
+// proxyList: 按优先顺序排列的代理列表
+func LocalClusterLoad(proxyList []models.ProxyItem) {
+	cluster := localClusters[os.Args[1]]
+	if len(os.Args) < 3 || os.Args[2] != "load" {
+		printClusterLoadUsage(cluster)
+		os.Exit(1)
+	}
+
+	args := os.Args[3:]
+	name, args, hasName := util.ExtractFlagValue(args, "--name", "-n", "--cluster", "-c", "--profile", "-p")
+	if !hasName {
+		name = cluster.DefaultName
+	}
+	listFile, args, hasFile := util.ExtractFlagValue(args, "-f", "--file")
+	parallel, args, hasParallel := util.ExtractFlagValue(args, "-j", "--parallel")
+
+	concurrency := config.PullConcurrency
+	if hasParallel {
+		n, err := strconv.Atoi(parallel)
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "错误: 无效的并发数: %s\n", parallel)
+			os.Exit(1)
+		}
+		concurrency = n
+	}
+
+	var images []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			fmt.Fprintf(os.Stderr, "错误: 不支持的选项 '%s'\n", arg)
+			printClusterLoadUsage(cluster)
+			os.Exit(1)
+		}
+		images = append(images, arg)
+	}
+	if hasFile {
+		fileImages, err := readImageListFile(listFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		images = append(images, fileImages...)
+	}
+	images = uniqueImages(images)
+	if len(images) == 0 {
+		printClusterLoadUsage(cluster)
+		os.Exit(1)
+	}
+
+	// 先确认集群存在，避免拉取完成后才发现集群名错误
+	nodes, err := cluster.listNodes(name)
+	if err == nil && len(nodes) == 0 {
+		err = fmt.Errorf("集群 %s 没有运行中的节点", name)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 获取 %s 集群 %s 的节点失败: %v\n", cluster.Tool, name, err)
+		os.Exit(1)
+	}
+
+	useImageLock()
+	results := pullWithFailover(images, proxyList, func(pending []string) []pullResult {
+		return pullImages(pending, nil, concurrency)
+	})
+
+	fmt.Printf("\n导入 %s 集群 %s（%d 个节点）\n", cluster.Tool, name, len(nodes))
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("❌ %s: %v\n", result.Image, result.Err)
+			failed++
+			continue
+		}
+
+		loaded, err := cluster.loadImage(name, nodes, localImageName(result.Image))
+		switch {
+		case err != nil:
+			fmt.Printf("❌ %s: %v\n", result.Image, err)
+			failed++
+		case loaded:
+			fmt.Printf("✅ %s: 已导入\n", result.Image)
+		default:
+			fmt.Printf("✅ %s: 节点上已存在，跳过\n", result.Image)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "错误: %d 个镜像导入失败\n", failed)
+		os.Exit(1)
+	}
+}
+
+// loadImage 将本地镜像导入集群，全部节点上都已有相同 ID 与标签的镜像时跳过
+// 返回: 是否执行了导入、错误
+func (c *localCluster) loadImage(cluster string, nodes []string, image string) (bool, error) {
+	info, err := inspectImage(image)
+	if err != nil {
+		return false, err
+	}
+
+	present := true
+	for _, node := range nodes {
+		if !c.nodeHasImage(cluster, node, image, info.ID) {
+			present = false
+			break
+		}
+	}
+	if present {
+		return false, nil
+	}
+
+	cmd := c.loadCmd(cluster, image)
+	if config.Debug {
+		fmt.Printf("执行命令: %s\n", strings.Join(cmd.Args, " "))
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return false, fmt.Errorf("导入失败: %v\n%s", err, indentOutput(string(output)))
+	}
+	return true, nil
+}
+
+// nodeHasImage 通过 crictl 判断节点上是否已有相同 ID 且带有该标签的镜像
+// 查询失败时视为不存在，由导入命令处理
+func (c *localCluster) nodeHasImage(cluster, node, image, id string) bool {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return false
+	}
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	want := ref.Name() + ":" + tag
+
+	output, err := c.crictlCmd(cluster, node, "images", "-o", "json").Output()
+	if err != nil {
+		if config.Debug {
+			fmt.Printf("查询节点 %s 的镜像失败: %v\n", node, err)
+		}
+		return false
+	}
+
+	var list crictlImageList
+	if err := json.Unmarshal(output, &list); err != nil {
+		return false
+	}
+	for _, item := range list.Images {
+		if item.ID != id {
+			continue
+		}
+		for _, repoTag := range item.RepoTags {
+			if repoTag == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commandLines 执行命令并返回非空的输出行
+func commandLines(cmd *exec.Cmd) ([]string, error) {
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, nil
+}
+
+// printClusterLoadUsage 输出 load 命令用法
+func printClusterLoadUsage(cluster *localCluster) {
+	fmt.Fprintf(os.Stderr, "用法: cnfast %s load <镜像>... [-f 镜像列表文件] [-j 并发数] [--name 集群名]\n", cluster.Tool)
+	fmt.Fprintf(os.Stderr, "未指定集群名时使用 %s\n", cluster.DefaultName)
+}
@@ -3,6 +3,7 @@ package services
 
 import (
 	"bufio"
+	"cnfast/config"
 	"cnfast/internal/models"
 	"fmt"
 	"os"
@@ -10,10 +11,109 @@ import (
 	"strings"
 )
 
+// 直连原始地址的策略
+const (
+	// DirectNever 所有代理失败后不直连
+	DirectNever = "never"
+
+	// DirectAsk 所有代理失败后询问用户是否直连
+	DirectAsk = "ask"
+
+	// DirectAlways 所有代理失败后自动直连
+	DirectAlways = "always"
+)
+
 // CommandBuilder 命令构建函数类型
 // 返回: cmd 命令对象, error 错误
 type CommandBuilder func(proxy models.ProxyItem) (*exec.Cmd, string, error)
 
+// ProxyAction 使用指定代理执行一次操作
+// proxy 为 nil 时表示不使用代理，直连原始地址
+type ProxyAction func(proxy *models.ProxyItem) error
+
+// FailoverOptions 代理失败切换选项
+type FailoverOptions struct {
+	// AutoNext 失败后自动切换到下一个代理，不再询问用户
+	AutoNext bool
+
+	// DirectFallback 所有代理失败后的直连策略（DirectNever/DirectAsk/DirectAlways）
+	DirectFallback string
+}
+
+// abortError 终止代理切换的错误，例如命令构建失败或用户取消
+type abortError struct {
+	err error
+}
+
+// Error 实现 error 接口
+func (e *abortError) Error() string {
+	return e.err.Error()
+}
+
+// abortFailover 包装错误，使代理切换立即终止
+func abortFailover(err error) error {
+	return &abortError{err: err}
+}
+
+// ExecuteWithProxyFailover 依次使用代理执行操作的通用框架
+// 按 proxyList 的顺序尝试，失败时切换到下一个代理，
+// 所有代理都失败后按直连策略决定是否直连原始地址
+// proxyList: 代理服务列表（调用方负责排序）
+// action: 使用单个代理执行的操作
+// actionName: 操作名称（如 "执行"、"拉取" 等）
+// opts: 切换选项
+func ExecuteWithProxyFailover(proxyList []models.ProxyItem, action ProxyAction, actionName string, opts FailoverOptions) error {
+	var lastErr error
+
+	for i := range proxyList {
+		proxy := proxyList[i]
+		fmt.Printf("使用代理: %s (评分: %d)\n", proxy.GetDisplayName(), proxy.Score)
+
+		err := action(&proxy)
+		if err == nil {
+			fmt.Printf("✅ 代理 %s %s成功\n", proxy.ID, actionName)
+			return nil
+		}
+		if _, ok := err.(*abortError); ok {
+			return err
+		}
+		lastErr = err
+
+		// 命令执行失败，检查是否还有更多代理可以尝试
+		if i < len(proxyList)-1 {
+			if opts.AutoNext {
+				fmt.Printf("\n⚠️  代理 %s %s失败: %v\n🔄 自动切换到下一个代理...\n\n", proxy.GetDisplayName(), actionName, err)
+				continue
+			}
+			// 询问用户是否尝试下一个代理
+			if askUserToRetry() {
+				fmt.Printf("\n🔄 尝试下一个代理...\n\n")
+				continue
+			}
+			return abortFailover(fmt.Errorf("用户取消操作"))
+		}
+	}
+
+	// 所有代理都失败了，按策略决定是否直连
+	if shouldFallbackToDirect(opts.DirectFallback, len(proxyList) > 0) {
+		fmt.Printf("\n🔄 不使用加速，直连原始地址%s...\n\n", actionName)
+		err := action(nil)
+		if err == nil {
+			fmt.Printf("✅ 直连%s成功\n", actionName)
+			return nil
+		}
+		if _, ok := err.(*abortError); ok {
+			return err
+		}
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		return fmt.Errorf("没有可用的代理服务")
+	}
+	return fmt.Errorf("所有代理都%s失败，最后一个错误: %w", actionName, lastErr)
+}
+
 // ExecuteWithProxyRetry 使用代理列表重试执行命令的通用框架
 // proxyList: 代理服务列表
 // cmdBuilder: 命令构建函数，根据代理构建具体的命令
@@ -22,43 +122,52 @@ func ExecuteWithProxyRetry(proxyList []models.ProxyItem, cmdBuilder CommandBuild
 	// 按评分排序代理列表
 	sortedProxies := sortProxiesByScore(proxyList)
 
-	// 尝试每个代理
-	for i, proxy := range sortedProxies {
-		fmt.Printf("使用代理: %s (评分: %d)\n", proxy.GetDisplayName(), proxy.Score)
-
+	err := ExecuteWithProxyFailover(sortedProxies, func(proxy *models.ProxyItem) error {
 		// 构建命令
-		cmd, _, err := cmdBuilder(proxy)
+		cmd, _, err := cmdBuilder(*proxy)
 		if err != nil {
-			fmt.Printf("构建命令失败: %v\n", err)
-			return
+			return abortFailover(fmt.Errorf("构建命令失败: %w", err))
 		}
 
 		// 执行命令并输出（不再隐藏敏感信息）
 		cmd.Stdin = os.Stdin
 		cmd.Stdout = os.Stdout
 		cmd.Stderr = os.Stderr
-		err = cmd.Run()
+		return cmd.Run()
+	}, actionName, FailoverOptions{DirectFallback: DirectNever})
 
-		if err == nil {
-			fmt.Printf("✅ 代理 %s %s成功\n", proxy.ID, actionName)
-			return
-		}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "\n❌ %v\n", err)
+		os.Exit(1)
+	}
+}
 
-		// 命令执行失败，检查是否还有更多代理可以尝试
-		if i < len(sortedProxies)-1 {
-			// 询问用户是否尝试下一个代理
-			if askUserToRetry() {
-				fmt.Printf("\n🔄 尝试下一个代理...\n\n")
-				continue
-			} else {
-				fmt.Println("用户取消操作")
-				os.Exit(1)
-			}
-		} else {
-			// 所有代理都失败了
-			fmt.Fprintf(os.Stderr, "\n❌ 所有代理都%s失败，最后一个错误: %v\n", actionName, err)
-			os.Exit(1)
+// dockerFailoverOptions 返回 docker 操作使用的代理切换选项
+// docker 代理失败时自动切换，直连策略由 CNFAST_DIRECT_FALLBACK 配置
+func dockerFailoverOptions() FailoverOptions {
+	return FailoverOptions{
+		AutoNext:       true,
+		DirectFallback: config.DirectFallback,
+	}
+}
+
+// shouldFallbackToDirect 根据策略判断是否直连原始地址
+// triedProxies: 是否已经尝试过代理（未尝试任何代理时 ask 策略不再询问）
+func shouldFallbackToDirect(policy string, triedProxies bool) bool {
+	switch strings.ToLower(policy) {
+	case DirectAlways:
+		return true
+	case DirectAsk:
+		if !triedProxies {
+			return true
 		}
+		fmt.Print("\n❌所有代理均失败，是否不使用加速直连原始地址？(y/n): ")
+		reader := bufio.NewReader(os.Stdin)
+		response, _ := reader.ReadString('\n')
+		response = strings.TrimSpace(strings.ToLower(response))
+		return response == "y" || response == "yes"
+	default:
+		return false
 	}
 }
 
@@ -77,6 +186,17 @@ func askUserToRetry() bool {
 	return response == "y" || response == "yes"
 }
 
+// preferProxy 将用户选择的代理排在首位，其余代理按评分降序排列
+func preferProxy(proxyList []models.ProxyItem, preferred models.ProxyItem) []models.ProxyItem {
+	ordered := []models.ProxyItem{preferred}
+	for _, proxy := range sortProxiesByScore(proxyList) {
+		if proxy.ID != preferred.ID {
+			ordered = append(ordered, proxy)
+		}
+	}
+	return ordered
+}
+
 // sortProxiesByScore 按评分排序代理列表
 func sortProxiesByScore(proxyList []models.ProxyItem) []models.ProxyItem {
 	// 创建副本避免修改原列表
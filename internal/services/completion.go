@@ -0,0 +1,495 @@
+// Package services 包含 shell 补全脚本与补全候选的逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/enums"
+	"cnfast/internal/models"
+
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxHistoryURLs 从 shell 历史中补全的 GitHub 地址数量上限
+const maxHistoryURLs = 100
+
+// reHistoryGitHubURL 匹配 shell 历史中的 GitHub 地址
+var reHistoryGitHubURL = regexp.MustCompile(`https?://github\.com/[^\s'"<>|;&()\\]+`)
+
+// completionCommand 补全用的命令定义
+type completionCommand struct {
+	// Subcommands 子命令
+	Subcommands map[string]*completionCommand
+
+	// Flags 不带参数值的选项
+	Flags []string
+
+	// ValueFlags 需要参数值的选项及其候选值，为 nil 时由 shell 补全文件名
+	ValueFlags map[string]func() []string
+
+	// Args 位置参数的候选值，positional 为已输入的位置参数
+	Args func(positional []string) []string
+}
+
+// completionTree 返回 cnfast 的命令定义
+func completionTree() *completionCommand {
+	clusterLoad := &completionCommand{
+		ValueFlags: map[string]func() []string{
+			"--name": nil, "-n": nil, "--cluster": nil, "-c": nil, "--profile": nil, "-p": nil,
+			"-f": nil, "--file": nil,
+			"-j": nil, "--parallel": nil,
+		},
+	}
+	cluster := &completionCommand{Subcommands: map[string]*completionCommand{"load": clusterLoad}}
+	compose := &completionCommand{Args: composeServiceNames}
+	shimsFlags := map[string]func() []string{"--dir": nil}
+
+	return &completionCommand{
+		Flags: []string{"-v", "--version", "-h", "--help"},
+		Subcommands: map[string]*completionCommand{
+			"git": {
+				Subcommands: map[string]*completionCommand{
+					"clone": {Args: argAt(0, historyGitHubURLs)},
+					"pull":  {Args: argAt(0, historyGitHubURLs)},
+					"down":  {Args: argAt(0, historyGitHubURLs)},
+					"setup": {
+						Flags: []string{"--revert"},
+						ValueFlags: map[string]func() []string{
+							"--scope":  staticValues(gitSetupScopes...),
+							"--switch": func() []string { return cachedProxyIDs(enums.ServiceGit) },
+						},
+					},
+				},
+			},
+			"docker": {
+				Subcommands: map[string]*completionCommand{
+					"pull": {
+						Flags: []string{"--platform-tag"},
+						ValueFlags: map[string]func() []string{
+							"-f": nil, "--file": nil,
+							"-j": nil, "--parallel": nil,
+							"--platform": staticValues("linux/amd64", "linux/arm64", "linux/arm/v7"),
+						},
+					},
+					"push":   {Args: argAt(0, localDockerImages)},
+					"build":  {},
+					"run":    {},
+					"create": {},
+					"buildx": {Subcommands: map[string]*completionCommand{"build": {}}},
+					"bundle": {
+						Subcommands: map[string]*completionCommand{
+							"create": {
+								Flags: []string{"--platform-tag"},
+								ValueFlags: map[string]func() []string{
+									"-f": nil, "--file": nil,
+									"-o": nil, "--output": nil,
+									"-j": nil, "--parallel": nil,
+									"--platform": nil,
+								},
+							},
+							"load": {},
+						},
+					},
+					"lock": {
+						ValueFlags: map[string]func() []string{"-f": nil, "--file": nil, "-c": nil, "--compose": nil, "-o": nil, "--output": nil},
+					},
+					"tags":           {},
+					"inspect-remote": {},
+					"prune-accel":    {Flags: []string{"-n", "--dry-run"}},
+					"sync": {
+						Flags:      []string{"--plain-http"},
+						ValueFlags: map[string]func() []string{"-f": nil, "--file": nil, "-j": nil, "--parallel": nil},
+					},
+					"compose": compose,
+				},
+			},
+			"docker-compose": compose,
+			"helm": {
+				Subcommands: map[string]*completionCommand{
+					"repo":       {Subcommands: map[string]*completionCommand{"add": {Args: argAt(1, historyGitHubURLs)}}},
+					"pull":       {},
+					"install":    {Flags: []string{"--no-prefetch"}},
+					"dependency": {Subcommands: map[string]*completionCommand{"update": {}}},
+				},
+			},
+			"k8s": {
+				Subcommands: map[string]*completionCommand{
+					"webhook": {
+						ValueFlags: map[string]func() []string{
+							"--listen": nil, "--tls-cert": nil, "--tls-key": nil,
+							"--namespace": nil, "-n": nil, "--label": nil, "--exclude-registry": nil,
+						},
+					},
+				},
+			},
+			"rewrite": {
+				Subcommands: map[string]*completionCommand{
+					"images": {
+						Flags:      []string{"-i", "--in-place", "--reverse"},
+						ValueFlags: map[string]func() []string{"-f": nil, "--file": nil},
+					},
+				},
+			},
+			"kind":     cluster,
+			"k3d":      cluster,
+			"minikube": cluster,
+			"devcontainer": {
+				Subcommands: map[string]*completionCommand{
+					"prefetch": {ValueFlags: map[string]func() []string{"-c": nil, "--config": nil, "-j": nil, "--parallel": nil}},
+				},
+			},
+			"shims": {
+				Subcommands: map[string]*completionCommand{
+					"install":   {ValueFlags: shimsFlags},
+					"uninstall": {ValueFlags: shimsFlags},
+				},
+			},
+			"completion": {Args: argAt(0, staticValues("bash", "zsh", "fish", "powershell"))},
+			"update":     {},
+		},
+	}
+}
+
+// staticValues 返回固定的候选值
+func staticValues(values ...string) func() []string {
+	return func() []string { return values }
+}
+
+// argAt 只在补全第 index 个位置参数时提供候选值
+func argAt(index int, values func() []string) func([]string) []string {
+	return func(positional []string) []string {
+		if len(positional) != index {
+			return nil
+		}
+		return values()
+	}
+}
+
+// Complete 处理隐藏的 __complete 命令，供补全脚本调用，每行输出一个候选值
+// args: 第一个参数为已输入完成的参数个数 n，之后为 cnfast 之后的参数，其中第 n 个（从 0 开始）为正在输入的参数
+// 没有候选值时不输出，由补全脚本改为补全文件名
+func Complete(args []string) {
+	if len(args) == 0 {
+		return
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return
+	}
+
+	words := args[1:]
+	if n > len(words) {
+		n = len(words)
+	}
+	current := ""
+	if n < len(words) {
+		current = words[n]
+	}
+
+	for _, candidate := range completeWords(words[:n], current) {
+		if strings.HasPrefix(candidate, current) {
+			fmt.Println(candidate)
+		}
+	}
+}
+
+// completeWords 根据已输入的参数返回当前参数的候选值
+func completeWords(previous []string, current string) []string {
+	command := completionTree()
+	var positional []string
+	for i := 0; i < len(previous); i++ {
+		word := previous[i]
+		if strings.HasPrefix(word, "-") {
+			if _, ok := command.ValueFlags[word]; ok {
+				i++
+			}
+			continue
+		}
+		if sub, ok := command.Subcommands[word]; ok && len(positional) == 0 {
+			command = sub
+			continue
+		}
+		positional = append(positional, word)
+	}
+
+	// 上一个参数是需要参数值的选项时补全参数值
+	if len(previous) > 0 {
+		if values, ok := command.ValueFlags[previous[len(previous)-1]]; ok {
+			if values == nil {
+				return nil
+			}
+			return values()
+		}
+	}
+
+	if strings.HasPrefix(current, "-") {
+		flags := append([]string{}, command.Flags...)
+		for flag := range command.ValueFlags {
+			flags = append(flags, flag)
+		}
+		sort.Strings(flags)
+		return flags
+	}
+
+	var candidates []string
+	if len(positional) == 0 {
+		for name := range command.Subcommands {
+			candidates = append(candidates, name)
+		}
+		sort.Strings(candidates)
+	}
+	if command.Args != nil {
+		candidates = append(candidates, command.Args(positional)...)
+	}
+	return candidates
+}
+
+// localDockerImages 返回本地镜像名（不含无标签镜像）
+func localDockerImages() []string {
+	lines, _ := commandLines(exec.Command("docker", "image", "ls", "--format", "{{.Repository}}:{{.Tag}}"))
+	var images []string
+	for _, line := range lines {
+		if !strings.Contains(line, "<none>") {
+			images = append(images, line)
+		}
+	}
+	return uniqueStrings(images)
+}
+
+// composeServiceNames 返回当前目录 compose 文件中尚未输入的 service 名称
+// 直接解析 YAML，不调用 docker compose，以免补全时等待
+func composeServiceNames(positional []string) []string {
+	composeFile := findComposeFile()
+	if composeFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(composeFile)
+	if err != nil {
+		return nil
+	}
+
+	var compose struct {
+		Services map[string]yaml.Node `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &compose); err != nil {
+		return nil
+	}
+
+	var names []string
+	for name := range compose.Services {
+		if !isCommandSupported(name, positional) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// historyGitHubURLs 从 shell 历史文件中提取 GitHub 地址，最近使用的在前
+func historyGitHubURLs() []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, file := range shellHistoryFiles() {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		lines := strings.Split(string(data), "\n")
+		for i := len(lines) - 1; i >= 0 && len(urls) < maxHistoryURLs; i-- {
+			for _, url := range reHistoryGitHubURL.FindAllString(lines[i], -1) {
+				if !seen[url] {
+					seen[url] = true
+					urls = append(urls, url)
+				}
+			}
+		}
+	}
+	return urls
+}
+
+// shellHistoryFiles 返回可能存在的 shell 历史文件
+func shellHistoryFiles() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	if histFile := os.Getenv("HISTFILE"); histFile != "" {
+		files = append(files, histFile)
+	}
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	files = append(files,
+		filepath.Join(home, ".bash_history"),
+		filepath.Join(home, ".zsh_history"),
+		filepath.Join(dataHome, "fish", "fish_history"),
+		filepath.Join(dataHome, "powershell", "PSReadLine", "ConsoleHost_history.txt"),
+	)
+	if runtime.GOOS == "windows" {
+		files = append(files, filepath.Join(os.Getenv("APPDATA"), "Microsoft", "Windows", "PowerShell", "PSReadLine", "ConsoleHost_history.txt"))
+	}
+	return uniqueStrings(files)
+}
+
+// proxyCacheFile 返回代理列表缓存文件路径（~/.cnfast/proxies-<类型>.json）
+func proxyCacheFile(proxyType enums.ProxyType) string {
+	return filepath.Join(config.HomeDir, "proxies-"+string(proxyType)+".json")
+}
+
+// saveProxyCache 保存最近一次获取的代理列表，供补全代理 ID 使用，失败时忽略
+func saveProxyCache(proxyType enums.ProxyType, proxyList []models.ProxyItem) {
+	data, err := json.MarshalIndent(proxyList, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(config.HomeDir, 0755); err != nil {
+		return
+	}
+	os.WriteFile(proxyCacheFile(proxyType), append(data, '\n'), 0644)
+}
+
+// cachedProxyIDs 返回缓存中的代理 ID，按评分排序
+func cachedProxyIDs(proxyType enums.ProxyType) []string {
+	data, err := os.ReadFile(proxyCacheFile(proxyType))
+	if err != nil {
+		return nil
+	}
+	var proxyList []models.ProxyItem
+	if err := json.Unmarshal(data, &proxyList); err != nil {
+		return nil
+	}
+
+	var ids []string
+	for _, proxy := range sortProxiesByScore(proxyList) {
+		ids = append(ids, proxy.ID)
+	}
+	return ids
+}
+
+// completionScripts 各 shell 的补全脚本
+var completionScripts = map[string]string{
+	"bash":       bashCompletionScript,
+	"zsh":        zshCompletionScript,
+	"fish":       fishCompletionScript,
+	"powershell": powershellCompletionScript,
+}
+
+// Completion 处理 cnfast completion <shell> 命令，输出补全脚本
+// args: completion 之后的全部参数
+func Completion(args []string) {
+	if len(args) != 1 {
+		printCompletionUsage()
+		os.Exit(1)
+	}
+	script, ok := completionScripts[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的 shell '%s'\n", args[0])
+		printCompletionUsage()
+		os.Exit(1)
+	}
+	fmt.Print(script)
+}
+
+// printCompletionUsage 输出 completion 命令用法
+func printCompletionUsage() {
+	fmt.Fprintln(os.Stderr, "用法: cnfast completion bash|zsh|fish|powershell")
+	fmt.Fprintln(os.Stderr, "  bash:       source <(cnfast completion bash)")
+	fmt.Fprintln(os.Stderr, "  zsh:        source <(cnfast completion zsh)")
+	fmt.Fprintln(os.Stderr, "  fish:       cnfast completion fish > ~/.config/fish/completions/cnfast.fish")
+	fmt.Fprintln(os.Stderr, "  powershell: cnfast completion powershell | Out-String | Invoke-Expression")
+}
+
+// bashCompletionScript bash 补全脚本
+// 安装了 bash-completion 时按 =: 之外的分隔符拆分参数，使 GitHub 地址作为一个整体补全
+const bashCompletionScript = `# cnfast bash 补全脚本
+# 使用: source <(cnfast completion bash)
+_cnfast() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null 2>&1; then
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+
+    local IFS=$'\n'
+    COMPREPLY=($(cnfast __complete "$((cword - 1))" "${words[@]:1:cword}" 2>/dev/null))
+    if declare -F __ltrim_colon_completions >/dev/null 2>&1; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+complete -o default -F _cnfast cnfast
+`
+
+// zshCompletionScript zsh 补全脚本
+const zshCompletionScript = `#compdef cnfast
+# cnfast zsh 补全脚本
+# 使用: source <(cnfast completion zsh)，或保存为 fpath 中的 _cnfast
+_cnfast() {
+    local -a candidates
+    candidates=("${(@f)$(cnfast __complete $((CURRENT - 2)) "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    candidates=(${candidates:#})
+    if (( ${#candidates} )); then
+        compadd -- "${candidates[@]}"
+    else
+        _files
+    fi
+}
+if [ "$funcstack[1]" = "_cnfast" ]; then
+    _cnfast "$@"
+else
+    compdef _cnfast cnfast
+fi
+`
+
+// fishCompletionScript fish 补全脚本
+const fishCompletionScript = `# cnfast fish 补全脚本
+# 使用: cnfast completion fish > ~/.config/fish/completions/cnfast.fish
+function __cnfast_complete
+    set -l tokens (commandline -opc)
+    set -l current (commandline -ct)
+    set -l candidates (cnfast __complete (math (count $tokens) - 1) $tokens[2..-1] $current 2>/dev/null)
+    if test (count $candidates) -gt 0
+        printf '%s\n' $candidates
+    else
+        __fish_complete_path $current
+    end
+end
+complete -c cnfast -f -a '(__cnfast_complete)'
+`
+
+// powershellCompletionScript PowerShell 补全脚本
+// 旧版 PowerShell 会丢弃传给外部命令的空字符串参数，因此先传入已完成的参数个数
+const powershellCompletionScript = `# cnfast PowerShell 补全脚本
+# 使用: cnfast completion powershell | Out-String | Invoke-Expression
+Register-ArgumentCompleter -Native -CommandName cnfast, cnfast.exe -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+
+    $words = @($commandAst.CommandElements |
+        Select-Object -Skip 1 |
+        Where-Object { $_.Extent.StartOffset -lt $cursorPosition } |
+        ForEach-Object { $_.ToString() })
+    $count = $words.Count
+    if ($wordToComplete -ne '') {
+        $count -= 1
+    }
+
+    & cnfast __complete $count @words 2>$null | ForEach-Object {
+        [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)
+    }
+}
+`
@@ -0,0 +1,369 @@
+// Package services 包含 devcontainer 镜像与 feature 预拉取逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/registry"
+	"cnfast/internal/pkg/util"
+
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// devcontainerConfigCandidates devcontainer 配置文件的默认位置
+var devcontainerConfigCandidates = []string{
+	filepath.Join(".devcontainer", "devcontainer.json"),
+	".devcontainer.json",
+}
+
+// dockerfileArgRegexp Dockerfile 中的变量引用，如 $VARIANT 或 ${VARIANT}
+var dockerfileArgRegexp = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}?`)
+
+// devcontainerConfig devcontainer.json 中与镜像相关的字段
+type devcontainerConfig struct {
+	// Image 基础镜像
+	Image string `json:"image"`
+
+	// DockerFile 旧版写法的 Dockerfile 路径（相对配置文件）
+	DockerFile string `json:"dockerFile"`
+
+	// Build 构建配置
+	Build *struct {
+		Dockerfile string            `json:"dockerfile"`
+		Context    string            `json:"context"`
+		Args       map[string]string `json:"args"`
+	} `json:"build"`
+
+	// Features 以 OCI 引用为键的 feature
+	Features map[string]interface{} `json:"features"`
+
+	// DockerComposeFile compose 文件路径，可以是字符串或数组
+	DockerComposeFile interface{} `json:"dockerComposeFile"`
+}
+
+// DevcontainerProxy 处理 cnfast devcontainer 命令
+// proxyList: 按优先顺序排列的 docker 代理列表
+func DevcontainerProxy(proxyList []models.ProxyItem) {
+	if len(os.Args) < 3 || os.Args[2] != "prefetch" {
+		fmt.Fprintln(os.Stderr, "用法: cnfast devcontainer prefetch [-c devcontainer.json] [-j 并发数]")
+		os.Exit(1)
+	}
+
+	args := os.Args[3:]
+	configFile, args, hasConfig := util.ExtractFlagValue(args, "-c", "--config")
+	parallel, args, hasParallel := util.ExtractFlagValue(args, "-j", "--parallel")
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的参数 '%s'\n", strings.Join(args, " "))
+		os.Exit(1)
+	}
+
+	concurrency := config.PullConcurrency
+	if hasParallel {
+		n, err := strconv.Atoi(parallel)
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "错误: 无效的并发数: %s\n", parallel)
+			os.Exit(1)
+		}
+		concurrency = n
+	}
+
+	if !hasConfig {
+		for _, candidate := range devcontainerConfigCandidates {
+			if _, err := os.Stat(candidate); err == nil {
+				configFile = candidate
+				break
+			}
+		}
+	}
+	if configFile == "" {
+		fmt.Fprintln(os.Stderr, "错误: 未找到 devcontainer 配置文件 (.devcontainer/devcontainer.json|.devcontainer.json)")
+		os.Exit(1)
+	}
+
+	images, features, err := collectDevcontainerRefs(configFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if len(images) == 0 && len(features) == 0 {
+		fmt.Println("未在 devcontainer 配置中找到需要预拉取的镜像或 feature")
+		return
+	}
+
+	for _, image := range images {
+		fmt.Printf("镜像: %s\n", image)
+	}
+	for _, feature := range features {
+		fmt.Printf("feature: %s\n", feature)
+	}
+
+	var results []pullResult
+	if len(images) > 0 {
+		results = append(results, pullWithFailover(images, proxyList, func(pending []string) []pullResult {
+			return pullImages(pending, nil, concurrency)
+		})...)
+	}
+	if len(features) > 0 {
+		results = append(results, pullWithFailover(features, proxyList, func(pending []string) []pullResult {
+			return runPullJobs(pending, concurrency, downloadFeature)
+		})...)
+	}
+
+	if printPullReport(results) > 0 {
+		os.Exit(1)
+	}
+}
+
+// collectDevcontainerRefs 解析 devcontainer 配置中引用的镜像与 feature
+// 返回: 镜像列表、feature 的 OCI 引用列表、错误
+func collectDevcontainerRefs(configFile string) ([]string, []string, error) {
+	data, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取 %s 失败: %w", configFile, err)
+	}
+
+	var cfg devcontainerConfig
+	if err := json.Unmarshal(util.JSONCToJSON(data), &cfg); err != nil {
+		return nil, nil, fmt.Errorf("解析 %s 失败: %w", configFile, err)
+	}
+
+	baseDir := filepath.Dir(configFile)
+	var images []string
+
+	// 1. 直接指定的镜像
+	if cfg.Image != "" {
+		images = append(images, cfg.Image)
+	}
+
+	// 2. Dockerfile 中的基础镜像
+	dockerfile := cfg.DockerFile
+	var buildArgs map[string]string
+	if cfg.Build != nil {
+		if cfg.Build.Dockerfile != "" {
+			dockerfile = cfg.Build.Dockerfile
+		}
+		buildArgs = cfg.Build.Args
+	}
+	if dockerfile != "" {
+		baseImages, err := dockerfileBaseImages(filepath.Join(baseDir, dockerfile), buildArgs)
+		if err != nil {
+			return nil, nil, err
+		}
+		images = append(images, baseImages...)
+	}
+
+	// 3. compose 文件中的镜像
+	var composeFiles []string
+	switch value := cfg.DockerComposeFile.(type) {
+	case string:
+		composeFiles = append(composeFiles, value)
+	case []interface{}:
+		for _, item := range value {
+			if file, ok := item.(string); ok {
+				composeFiles = append(composeFiles, file)
+			}
+		}
+	}
+	for _, file := range composeFiles {
+		composeImages, err := loadComposeImages(filepath.Join(baseDir, file))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, item := range composeImages {
+			images = append(images, item.Image)
+		}
+	}
+
+	// 4. OCI feature，本地目录与 tar 包地址不需要预拉取
+	var features []string
+	for feature := range cfg.Features {
+		if strings.HasPrefix(feature, ".") || strings.Contains(feature, "://") {
+			continue
+		}
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+
+	return uniqueImages(images), features, nil
+}
+
+// dockerfileBaseImages 解析 Dockerfile 中 FROM 引用的外部镜像
+// 支持 ARG 默认值与构建参数替换，跳过多阶段构建中引用前序阶段的 FROM 和 scratch
+func dockerfileBaseImages(path string, buildArgs map[string]string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Dockerfile 失败: %w", err)
+	}
+	defer file.Close()
+
+	args := make(map[string]string)
+	stages := make(map[string]bool)
+	var images []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			// 只有第一个 FROM 之前的 ARG 可用于 FROM
+			if len(images) > 0 || len(stages) > 0 {
+				continue
+			}
+			parts := strings.SplitN(fields[1], "=", 2)
+			if value, ok := buildArgs[parts[0]]; ok {
+				args[parts[0]] = value
+			} else if len(parts) == 2 {
+				args[parts[0]] = strings.Trim(parts[1], `"'`)
+			}
+		case "FROM":
+			rest := fields[1:]
+			for len(rest) > 0 && strings.HasPrefix(rest[0], "--") {
+				rest = rest[1:]
+			}
+			if len(rest) == 0 {
+				continue
+			}
+			if len(rest) >= 3 && strings.EqualFold(rest[1], "AS") {
+				stages[strings.ToLower(rest[2])] = true
+			}
+
+			image, ok := expandDockerfileArgs(rest[0], args)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "警告: 无法解析 FROM %s 中的变量，已跳过\n", rest[0])
+				continue
+			}
+			if image == "scratch" || stages[strings.ToLower(image)] {
+				continue
+			}
+			images = append(images, image)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 Dockerfile 失败: %w", err)
+	}
+	return images, nil
+}
+
+// expandDockerfileArgs 替换 $NAME、${NAME} 与 ${NAME:-默认值} 形式的变量
+// 存在无法解析的变量时返回 false
+func expandDockerfileArgs(value string, args map[string]string) (string, bool) {
+	ok := true
+	expanded := dockerfileArgRegexp.ReplaceAllStringFunc(value, func(match string) string {
+		groups := dockerfileArgRegexp.FindStringSubmatch(match)
+		if v, exists := args[groups[1]]; exists && v != "" {
+			return v
+		}
+		if strings.Contains(match, ":-") {
+			return groups[2]
+		}
+		ok = false
+		return match
+	})
+	return expanded, ok
+}
+
+// featureCacheDir 返回 feature 的本地缓存目录
+func featureCacheDir(ref *reference.Reference, tag string) string {
+	return filepath.Join(config.HomeDir, "features", ref.Domain, filepath.FromSlash(ref.Path), tag)
+}
+
+// downloadFeature 经加速域名下载 devcontainer feature 的 OCI 制品
+// 清单与各层保存到 ~/.cnfast/features/<registry>/<path>/<tag>/，已下载且摘要一致的层会跳过
+// feature: feature 的 OCI 引用，如 ghcr.io/devcontainers/features/node:1
+func downloadFeature(feature string, out io.Writer) error {
+	ref, err := reference.Parse(feature)
+	if err != nil {
+		return err
+	}
+	host, repo, target, err := remoteTarget(feature)
+	if err != nil {
+		return err
+	}
+	if host != registry.APIHost(ref.Domain) {
+		fmt.Fprintf(out, "feature 加速: %s -> %s/%s\n", feature, host, repo)
+	}
+
+	client := registry.NewClient(registryAuthFunc(feature))
+	ctx := context.Background()
+	manifest, err := client.GetManifest(ctx, host, repo, target)
+	if err != nil {
+		return err
+	}
+	if manifest.IsIndex() {
+		return fmt.Errorf("%s 不是 feature 制品", feature)
+	}
+
+	dir := featureCacheDir(ref, strings.Replace(target, ":", "-", 1))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), manifest.Raw, 0644); err != nil {
+		return fmt.Errorf("保存清单失败: %w", err)
+	}
+
+	for _, layer := range manifest.Layers {
+		name := filepath.Base(layer.Annotations["org.opencontainers.image.title"])
+		if name == "" || name == "." || name == string(filepath.Separator) {
+			name = strings.Replace(layer.Digest, ":", "-", 1) + ".tar"
+		}
+		path := filepath.Join(dir, name)
+
+		if digest, err := fileSHA256(path); err == nil && digest == layer.Digest {
+			fmt.Fprintf(out, "已存在 %s\n", path)
+			continue
+		}
+		if err := downloadBlobTo(ctx, client, host, repo, layer, path); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "已下载 %s (%s)\n", path, util.FormatSize(layer.Size))
+	}
+	return nil
+}
+
+// downloadBlobTo 下载 blob 到文件并校验摘要
+// 先写入临时文件，校验通过后再重命名，避免留下不完整的文件
+func downloadBlobTo(ctx context.Context, client *registry.Client, host, repo string, blob registry.Descriptor, path string) error {
+	content, _, err := client.OpenBlob(ctx, host, repo, blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("下载 %s 失败: %w", blob.Digest, err)
+	}
+
+	if digest := "sha256:" + hex.EncodeToString(hasher.Sum(nil)); digest != blob.Digest {
+		return fmt.Errorf("%s 的内容与摘要不一致（实际 %s）", blob.Digest, digest)
+	}
+	return os.Rename(tmp.Name(), path)
+}
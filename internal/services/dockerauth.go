@@ -0,0 +1,480 @@
+// Package services 包含 Docker 仓库凭据处理逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/registry"
+
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dockerHubAuthKey Docker Hub 在 config.json 与凭据助手中使用的键
+const dockerHubAuthKey = "https://index.docker.io/v1/"
+
+// credentialProbeTimeout 匿名探测加速域名是否需要认证的超时时间
+const credentialProbeTimeout = 15 * time.Second
+
+// dockerConfigFile ~/.docker/config.json 中与凭据相关的字段
+type dockerConfigFile struct {
+	// Auths 以 registry 为键的凭据
+	Auths map[string]dockerAuthEntry `json:"auths"`
+
+	// CredsStore 全局凭据助手名称，如 desktop、osxkeychain
+	CredsStore string `json:"credsStore,omitempty"`
+
+	// CredHelpers 以 registry 为键的凭据助手名称
+	CredHelpers map[string]string `json:"credHelpers,omitempty"`
+
+	// CurrentContext docker context use 选择的上下文
+	CurrentContext string `json:"currentContext,omitempty"`
+}
+
+// dockerAuthEntry config.json 中单个 registry 的凭据
+type dockerAuthEntry struct {
+	// Auth base64 编码的 "用户名:密码"
+	Auth string `json:"auth,omitempty"`
+
+	// IdentityToken 身份令牌（部分 registry 登录后使用）
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// registryCredential 解析后的仓库凭据
+type registryCredential struct {
+	// Username 用户名
+	Username string
+
+	// Secret 密码或令牌
+	Secret string
+
+	// IdentityToken 身份令牌，存在时优先使用
+	IdentityToken string
+}
+
+var (
+	// credentialCache 凭据查询缓存，避免并发拉取时重复调用凭据助手
+	credentialCache   = make(map[string]*registryCredential)
+	credentialCacheMu sync.Mutex
+)
+
+// dockerConfigDir 返回 Docker CLI 配置目录
+func dockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".docker"
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// loadDockerConfigFile 读取 Docker CLI 配置文件，文件不存在时返回空配置
+func loadDockerConfigFile() (*dockerConfigFile, error) {
+	cfg := &dockerConfigFile{}
+	data, err := os.ReadFile(filepath.Join(dockerConfigDir(), "config.json"))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 Docker 配置失败: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("解析 Docker 配置失败: %w", err)
+	}
+	return cfg, nil
+}
+
+// registryAuthKeys 返回 registry 在 config.json 中可能使用的键
+func registryAuthKeys(registry string) []string {
+	if registry == reference.DefaultDomain || registry == "index.docker.io" || registry == "registry-1.docker.io" {
+		return []string{dockerHubAuthKey, "index.docker.io", "docker.io", "registry-1.docker.io"}
+	}
+	return []string{registry, "https://" + registry, "http://" + registry}
+}
+
+// lookupRegistryCredential 查询 registry 的凭据
+// 查找顺序与 Docker CLI 一致：credHelpers -> credsStore -> auths
+// 未找到凭据时返回 nil
+func lookupRegistryCredential(registry string) (*registryCredential, error) {
+	credentialCacheMu.Lock()
+	defer credentialCacheMu.Unlock()
+
+	if cred, ok := credentialCache[registry]; ok {
+		return cred, nil
+	}
+
+	cfg, err := loadDockerConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := registryAuthKeys(registry)
+	var cred *registryCredential
+
+	// 1. 针对单个 registry 的凭据助手
+	for _, key := range keys {
+		if helper, ok := cfg.CredHelpers[key]; ok {
+			cred, err = getHelperCredential(helper, key)
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	// 2. 全局凭据助手
+	if cred == nil && cfg.CredsStore != "" {
+		for _, key := range keys {
+			cred, err = getHelperCredential(cfg.CredsStore, key)
+			if err != nil {
+				return nil, err
+			}
+			if cred != nil {
+				break
+			}
+		}
+	}
+
+	// 3. 配置文件中明文保存的凭据
+	if cred == nil {
+		for _, key := range keys {
+			if entry, ok := cfg.Auths[key]; ok && (entry.Auth != "" || entry.IdentityToken != "") {
+				cred, err = decodeAuthEntry(entry)
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+	}
+
+	credentialCache[registry] = cred
+	return cred, nil
+}
+
+// getHelperCredential 通过 docker-credential-<helper> 获取凭据
+// 凭据助手中不存在该 registry 时返回 nil
+func getHelperCredential(helper, serverURL string) (*registryCredential, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		// 凭据助手对不存在的凭据返回非零退出码，并输出 "credentials not found"
+		if strings.Contains(string(output)+stderr.String(), "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("调用凭据助手 docker-credential-%s 失败: %w", helper, err)
+	}
+
+	var result struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("解析凭据助手输出失败: %w", err)
+	}
+	if result.Secret == "" {
+		return nil, nil
+	}
+
+	// 凭据助手以 <token> 作为用户名表示身份令牌
+	if result.Username == "<token>" {
+		return &registryCredential{IdentityToken: result.Secret}, nil
+	}
+	return &registryCredential{Username: result.Username, Secret: result.Secret}, nil
+}
+
+// decodeAuthEntry 解码 config.json 中的凭据
+func decodeAuthEntry(entry dockerAuthEntry) (*registryCredential, error) {
+	cred := &registryCredential{IdentityToken: entry.IdentityToken}
+	if entry.Auth == "" {
+		return cred, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("解码 Docker 凭据失败: %w", err)
+	}
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Docker 凭据格式无效")
+	}
+	cred.Username = parts[0]
+	cred.Secret = parts[1]
+	return cred, nil
+}
+
+// imageDomain 返回镜像引用中的 registry 域名
+func imageDomain(image string) string {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return ""
+	}
+	return ref.Domain
+}
+
+// normalizeRegistryHost 统一镜像源或加速域名的写法，用于比较
+// 去掉协议与末尾的 /，Docker Hub 的各种别名统一为 docker.io
+func normalizeRegistryHost(value string) string {
+	value = strings.TrimSpace(value)
+	if i := strings.Index(value, "://"); i >= 0 {
+		value = value[i+3:]
+	}
+	value = strings.ToLower(strings.TrimRight(value, "/"))
+	switch value {
+	case "index.docker.io", "registry-1.docker.io", "index.docker.io/v1":
+		return reference.DefaultDomain
+	}
+	return value
+}
+
+// credentialForwardingTrusted 判断用户是否允许把原始仓库的凭据提供给加速域名
+// 信任列表来自 CNFAST_FORWARD_CREDENTIALS 与配置文件中的 forwardCredentials，
+// 其中的条目可以是镜像源（信任它映射出的加速域名），也可以是加速域名（信任该代理）
+func credentialForwardingTrusted(originalDomain, accelDomain string) bool {
+	trusted := append(append([]string{}, config.ForwardCredentials...), config.User.ForwardCredentials...)
+	for _, entry := range trusted {
+		switch normalizeRegistryHost(entry) {
+		case normalizeRegistryHost(originalDomain), normalizeRegistryHost(accelDomain):
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedCredential 返回访问加速镜像时可以转发的原始仓库凭据
+// 同时满足以下条件才转发，否则返回 nil：
+//   - 加速域名正是当前映射表为原始仓库生成的域名
+//   - 用户信任该镜像源或该加速域名
+//   - 加速域名拒绝了匿名请求（HTTP 401/403），公开镜像不会带上凭据
+//
+// actions: 需要的操作，"pull" 或 "push"
+func forwardedCredential(original, accelerated, actions string) *registryCredential {
+	originalDomain := imageDomain(original)
+	accelDomain := imageDomain(accelerated)
+	if originalDomain == "" || accelDomain == "" || originalDomain == accelDomain {
+		return nil
+	}
+	if imageDomain(replaceImageWithSpecificDomain(original)) != accelDomain {
+		return nil
+	}
+	if !credentialForwardingTrusted(originalDomain, accelDomain) {
+		return nil
+	}
+
+	cred, err := lookupRegistryCredential(originalDomain)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 读取 %s 的凭据失败: %v\n", originalDomain, err)
+		return nil
+	}
+	if cred == nil || !acceleratorDeniesAnonymous(accelerated, actions) {
+		return nil
+	}
+
+	if config.Debug {
+		fmt.Printf("%s 拒绝匿名访问，使用 %s 的凭据\n", accelDomain, originalDomain)
+	}
+	return cred
+}
+
+var (
+	// anonymousDenied 加速镜像是否拒绝匿名访问的探测结果，按 操作+镜像 缓存
+	anonymousDenied   = make(map[string]bool)
+	anonymousDeniedMu sync.Mutex
+)
+
+// acceleratorDeniesAnonymous 以匿名身份访问加速镜像，判断加速域名是否要求认证
+// 拉取时查询清单摘要，推送时尝试发起上传；网络错误等其他失败视为不需要凭据
+// actions: 需要的操作，"pull" 或 "push"
+func acceleratorDeniesAnonymous(accelerated, actions string) bool {
+	anonymousDeniedMu.Lock()
+	defer anonymousDeniedMu.Unlock()
+
+	key := actions + "|" + accelerated
+	if denied, ok := anonymousDenied[key]; ok {
+		return denied
+	}
+
+	ref, err := reference.Parse(accelerated)
+	if err != nil {
+		return false
+	}
+	target := ref.Digest
+	if target == "" {
+		target = ref.Tag
+	}
+	if target == "" {
+		target = "latest"
+	}
+
+	client := registry.NewClient(nil)
+	ctx, cancel := context.WithTimeout(context.Background(), credentialProbeTimeout)
+	defer cancel()
+
+	var denied bool
+	if actions == "push" {
+		allowed, err := client.PushAllowed(ctx, ref.Domain, ref.Path)
+		denied = err == nil && !allowed
+	} else {
+		_, err := client.ResolveDigest(ctx, ref.Domain, ref.Path, target)
+		denied = registry.IsAuthError(err)
+	}
+
+	anonymousDenied[key] = denied
+	return denied
+}
+
+// prepareAcceleratedAuth 为加速域名准备原始仓库的凭据
+// 满足转发条件（见 forwardedCredential）时，创建写入了加速域名凭据的临时 Docker 配置目录，
+// 其余设置与用户的配置目录一致，调用方通过 docker --config 使用该目录，结束后调用清理函数删除
+// actions: 需要的操作，"pull" 或 "push"
+// 返回: 临时配置目录（无需凭据时为空）、清理函数
+func prepareAcceleratedAuth(original, accelerated, actions string) (string, func()) {
+	noop := func() {}
+	cred := forwardedCredential(original, accelerated, actions)
+	if cred == nil {
+		return "", noop
+	}
+	accelDomain := imageDomain(accelerated)
+
+	entry := dockerAuthEntry{IdentityToken: cred.IdentityToken}
+	if cred.Username != "" || cred.Secret != "" {
+		entry.Auth = base64.StdEncoding.EncodeToString([]byte(cred.Username + ":" + cred.Secret))
+	}
+	data, err := acceleratedDockerConfig(accelDomain, entry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v\n", err)
+		return "", noop
+	}
+
+	dir, err := os.MkdirTemp("", "cnfast-docker-config-*")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 创建临时凭据目录失败: %v\n", err)
+		return "", noop
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), data, 0600); err != nil {
+		cleanup()
+		fmt.Fprintf(os.Stderr, "警告: 写入临时凭据失败: %v\n", err)
+		return "", noop
+	}
+	linkDockerConfigEntries(dir)
+
+	return dir, cleanup
+}
+
+// acceleratedDockerConfig 复制用户的 config.json 并写入加速域名的凭据
+// 保留 currentContext、proxies、插件目录等其他设置；
+// 全局凭据助手（credsStore）与该域名的 credHelpers 会覆盖 auths，因此从副本中去掉，
+// 命令只访问加速域名，不需要其他仓库的凭据
+func acceleratedDockerConfig(accelDomain string, entry dockerAuthEntry) ([]byte, error) {
+	cfg := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(filepath.Join(dockerConfigDir(), "config.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取 Docker 配置失败: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("解析 Docker 配置失败: %w", err)
+		}
+	}
+
+	auths := make(map[string]json.RawMessage)
+	if raw, ok := cfg["auths"]; ok {
+		if err := json.Unmarshal(raw, &auths); err != nil {
+			return nil, fmt.Errorf("解析 Docker 配置中的 auths 失败: %w", err)
+		}
+	}
+	if auths[accelDomain], err = json.Marshal(entry); err != nil {
+		return nil, err
+	}
+	if cfg["auths"], err = json.Marshal(auths); err != nil {
+		return nil, err
+	}
+
+	delete(cfg, "credsStore")
+	if raw, ok := cfg["credHelpers"]; ok {
+		helpers := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(raw, &helpers); err == nil {
+			delete(helpers, accelDomain)
+			if cfg["credHelpers"], err = json.Marshal(helpers); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return json.MarshalIndent(cfg, "", "\t")
+}
+
+// linkDockerConfigEntries 将用户配置目录中除 config.json 以外的内容链接到临时配置目录
+// docker --config 同时决定 contexts、cli-plugins 等目录的位置，缺少它们时会改用默认上下文、找不到插件
+func linkDockerConfigEntries(dir string) {
+	entries, err := os.ReadDir(dockerConfigDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.Name() == "config.json" {
+			continue
+		}
+		source := filepath.Join(dockerConfigDir(), entry.Name())
+		if err := os.Symlink(source, filepath.Join(dir, entry.Name())); err != nil && config.Debug {
+			fmt.Printf("链接 %s 失败: %v\n", source, err)
+		}
+	}
+}
+
+// newAcceleratedDockerCmd 创建访问加速域名的 docker 命令
+// 需要转发原始仓库的凭据时自动附加 --config 参数
+// 返回: 命令、执行结束后需要调用的清理函数
+func newAcceleratedDockerCmd(original, accelerated string, args ...string) (*exec.Cmd, func()) {
+	actions := "pull"
+	if len(args) > 0 && args[0] == "push" {
+		actions = "push"
+	}
+	configDir, cleanup := prepareAcceleratedAuth(original, accelerated, actions)
+	if configDir != "" {
+		args = append([]string{"--config", configDir}, args...)
+	}
+	return exec.Command("docker", args...), cleanup
+}
+
+// registryAuthFunc 返回 Registry API 客户端使用的凭据查询函数
+// 原始仓库使用自身凭据；加速域名只在满足转发条件（见 forwardedCredential）时使用原始仓库的凭据，
+// 其他主机不提供凭据
+// original: 原始镜像名
+func registryAuthFunc(original string) registry.AuthFunc {
+	domain := imageDomain(original)
+	accelerated := replaceImageWithSpecificDomain(original)
+	return func(host string) (string, string) {
+		var cred *registryCredential
+		switch host {
+		case domain, registry.APIHost(domain):
+			var err error
+			if cred, err = lookupRegistryCredential(domain); err != nil {
+				fmt.Fprintf(os.Stderr, "警告: 读取 %s 的凭据失败: %v\n", domain, err)
+			}
+		case imageDomain(accelerated):
+			cred = forwardedCredential(original, accelerated, "pull")
+		}
+		if cred == nil {
+			return "", ""
+		}
+		return cred.Username, cred.Secret
+	}
+}
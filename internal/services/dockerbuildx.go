@@ -0,0 +1,198 @@
+// Package services 包含 docker buildx 构建加速逻辑
+package services
+
+import (
+	"cnfast/config"
+
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// buildxBuilderName cnfast 专用的 buildx 构建器名称
+const buildxBuilderName = "cnfast"
+
+// buildxOutputFlags 指定了构建结果去向的 buildx build 选项
+var buildxOutputFlags = []string{"--load", "--push", "-o", "--output"}
+
+// dockerBuildx 处理 cnfast docker buildx 命令
+// build 子命令使用配置了镜像加速的专用构建器，其余子命令原样透传
+// 镜像源配置由当前代理的加速映射生成
+// args: buildx 之后的全部参数
+func dockerBuildx(args []string) {
+	if len(args) == 0 || args[0] != "build" {
+		runBuildxCommand(args)
+		return
+	}
+
+	// 用户指定了构建器时不替换，只能由该构建器自身的配置决定是否加速
+	if hasFlag(args[1:], "--builder") {
+		fmt.Fprintln(os.Stderr, "警告: 已指定 --builder，BuildKit 拉取基础镜像不会经过加速服务")
+		runBuildxCommand(args)
+		return
+	}
+
+	configFile, changed, err := writeBuildkitdConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if err := ensureBuildxBuilder(configFile, changed); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	buildArgs := []string{"build", "--builder", buildxBuilderName}
+
+	// docker-container 驱动的构建结果默认只保存在构建缓存中，
+	// 未指定输出且为单平台构建时导入本地镜像，与默认构建器的行为一致
+	if !hasAnyFlag(args[1:], buildxOutputFlags) && !isMultiPlatformBuild(args[1:]) {
+		buildArgs = append(buildArgs, "--load")
+	}
+
+	runBuildxCommand(append(buildArgs, args[1:]...))
+}
+
+// buildkitdMirrors 根据当前的加速映射生成 BuildKit 镜像源配置
+// 返回: 镜像源域名 -> 镜像地址（可带路径前缀）
+// 包含 {path} 且无法表示为路径前缀的模板会被跳过
+func buildkitdMirrors() map[string]string {
+	mirrors := make(map[string]string, len(registryToAccelDomain))
+	for registry, accelDomain := range registryToAccelDomain {
+		const pathSuffix = "/{path}"
+		if strings.HasSuffix(accelDomain, pathSuffix) {
+			accelDomain = strings.TrimSuffix(accelDomain, pathSuffix)
+		}
+		if strings.Contains(accelDomain, "{") {
+			fmt.Fprintf(os.Stderr, "警告: %s 的加速地址 %s 无法用作 BuildKit 镜像源，已跳过\n", registry, accelDomain)
+			continue
+		}
+		mirrors[registry] = accelDomain
+	}
+	return mirrors
+}
+
+// renderBuildkitdConfig 生成 buildkitd.toml 内容
+func renderBuildkitdConfig(mirrors map[string]string) []byte {
+	registries := make([]string, 0, len(mirrors))
+	for registry := range mirrors {
+		registries = append(registries, registry)
+	}
+	sort.Strings(registries)
+
+	var buf bytes.Buffer
+	buf.WriteString("# 由 cnfast 生成，请勿手动修改\n")
+	for _, registry := range registries {
+		fmt.Fprintf(&buf, "\n[registry.%s]\n", strconv.Quote(registry))
+		fmt.Fprintf(&buf, "  mirrors = [%s]\n", strconv.Quote(mirrors[registry]))
+	}
+	return buf.Bytes()
+}
+
+// writeBuildkitdConfig 将当前加速映射写入 ~/.cnfast/buildx/buildkitd.toml
+// 返回: 配置文件路径、内容是否发生变化、错误
+func writeBuildkitdConfig() (string, bool, error) {
+	dir := filepath.Join(config.HomeDir, "buildx")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", false, fmt.Errorf("创建 buildx 配置目录失败: %w", err)
+	}
+
+	path := filepath.Join(dir, "buildkitd.toml")
+	data := renderBuildkitdConfig(buildkitdMirrors())
+	if old, err := os.ReadFile(path); err == nil && bytes.Equal(old, data) {
+		return path, false, nil
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", false, fmt.Errorf("写入 buildkitd 配置失败: %w", err)
+	}
+	return path, true, nil
+}
+
+// ensureBuildxBuilder 确保 cnfast 构建器存在且使用最新的配置
+// 配置变化时重建构建器，并保留构建缓存
+func ensureBuildxBuilder(configFile string, changed bool) error {
+	exists := exec.Command("docker", "buildx", "inspect", buildxBuilderName).Run() == nil
+	if exists && !changed {
+		return nil
+	}
+
+	if exists {
+		fmt.Printf("加速配置已变化，重建 buildx 构建器 %s\n", buildxBuilderName)
+		if output, err := exec.Command("docker", "buildx", "rm", "--keep-state", buildxBuilderName).CombinedOutput(); err != nil {
+			return fmt.Errorf("删除 buildx 构建器失败: %v\n%s", err, output)
+		}
+	} else {
+		fmt.Printf("创建 buildx 构建器 %s\n", buildxBuilderName)
+	}
+
+	output, err := exec.Command("docker", "buildx", "create",
+		"--name", buildxBuilderName,
+		"--driver", "docker-container",
+		"--config", configFile,
+	).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("创建 buildx 构建器失败: %v\n%s", err, output)
+	}
+	return nil
+}
+
+// runBuildxCommand 执行 docker buildx 命令，失败时以相同方式退出
+func runBuildxCommand(args []string) {
+	args = append([]string{"buildx"}, args...)
+	if config.Debug {
+		fmt.Printf("执行命令: docker %s\n", strings.Join(args, " "))
+	}
+
+	cmd := exec.Command("docker", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "命令执行失败: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// hasFlag 判断参数中是否包含指定选项（--flag value 或 --flag=value）
+func hasFlag(args []string, flag string) bool {
+	for _, arg := range args {
+		if arg == flag || strings.HasPrefix(arg, flag+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAnyFlag 判断参数中是否包含任一选项
+func hasAnyFlag(args []string, flags []string) bool {
+	for _, flag := range flags {
+		if hasFlag(args, flag) {
+			return true
+		}
+	}
+	return false
+}
+
+// isMultiPlatformBuild 判断是否同时构建多个平台（多平台镜像无法导入默认的镜像存储）
+func isMultiPlatformBuild(args []string) bool {
+	count := 0
+	for i, arg := range args {
+		var value string
+		switch {
+		case arg == "--platform" && i+1 < len(args):
+			value = args[i+1]
+		case strings.HasPrefix(arg, "--platform="):
+			value = strings.TrimPrefix(arg, "--platform=")
+		default:
+			continue
+		}
+		count += len(splitPlatforms(value))
+	}
+	return count > 1
+}
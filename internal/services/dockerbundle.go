@@ -0,0 +1,406 @@
+// Package services 包含离线镜像包的导出与导入逻辑
+package services
+
+import (
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/util"
+
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 离线镜像包内的文件名
+const (
+	// bundleManifestName 镜像包清单文件名
+	bundleManifestName = "manifest.json"
+
+	// bundleArchiveName docker save 归档文件名
+	bundleArchiveName = "images.tar"
+
+	// defaultBundleOutput 默认输出文件名
+	defaultBundleOutput = "cnfast-bundle.tar.gz"
+)
+
+// bundleManifest 离线镜像包清单
+// 记录包内镜像及其摘要，导入时用于校验完整性
+type bundleManifest struct {
+	// Version 清单格式版本
+	Version int `json:"version"`
+
+	// CreatedAt 创建时间
+	CreatedAt string `json:"createdAt"`
+
+	// Archive 包内 docker save 归档的文件名
+	Archive string `json:"archive"`
+
+	// ArchiveDigest docker save 归档的 sha256 摘要
+	ArchiveDigest string `json:"archiveDigest"`
+
+	// Images 包内的镜像列表
+	Images []bundleImage `json:"images"`
+}
+
+// bundleImage 离线镜像包中的单个镜像
+type bundleImage struct {
+	// Name 原始镜像名
+	Name string `json:"name"`
+
+	// ID 镜像 ID（配置文件摘要），导入后用于校验
+	ID string `json:"id"`
+
+	// Digest 拉取时的仓库清单摘要
+	Digest string `json:"digest,omitempty"`
+}
+
+// DockerBundle 处理 cnfast docker bundle 子命令
+// args: bundle 之后的全部参数
+// proxyList: 按优先顺序排列的代理列表（load 子命令不需要）
+func DockerBundle(args []string, proxyList []models.ProxyItem) {
+	if len(args) == 0 {
+		printBundleUsage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch args[0] {
+	case "create":
+		err = createImageBundle(args[1:], proxyList)
+	case "load":
+		err = loadImageBundle(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "错误: 不支持的 bundle 子命令 '%s'\n", args[0])
+		printBundleUsage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printBundleUsage 输出 bundle 子命令用法
+func printBundleUsage() {
+	fmt.Fprintln(os.Stderr, "用法:")
+	fmt.Fprintln(os.Stderr, "  cnfast docker bundle create [-f 镜像列表文件] [-o 输出文件] [镜像...]")
+	fmt.Fprintln(os.Stderr, "  cnfast docker bundle load <镜像包文件>")
+}
+
+// createImageBundle 通过加速域名拉取镜像并导出为离线镜像包
+func createImageBundle(args []string, proxyList []models.ProxyItem) error {
+	output, args, _ := util.ExtractFlagValue(args, "-o", "--output")
+	if output == "" {
+		output = defaultBundleOutput
+	}
+
+	images, pullFlags, concurrency, err := parsePullArgs(args)
+	if err != nil {
+		printBundleUsage()
+		return err
+	}
+
+	// 1. 通过加速域名拉取并还原为原始名称
+	// 加速标签删除后本地镜像不再带有仓库摘要，拉取时记录下来
+	var mu sync.Mutex
+	digests := make(map[string]string)
+	results := pullWithFailover(images, proxyList, func(pending []string) []pullResult {
+		return runPullJobs(pending, concurrency, func(image string, out io.Writer) error {
+			digest, err := pullImageDigest(image, pullFlags, out)
+			mu.Lock()
+			digests[image] = digest
+			mu.Unlock()
+			return err
+		})
+	})
+	if printPullReport(results) > 0 {
+		return fmt.Errorf("部分镜像拉取失败，已取消打包")
+	}
+
+	// 2. 记录每个镜像的 ID 与仓库摘要
+	// 摘要引用的镜像在本地只有拉取时创建的 name:sha256-<hex> 标签
+	manifest := bundleManifest{
+		Version:   1,
+		CreatedAt: time.Now().Format(time.RFC3339),
+		Archive:   bundleArchiveName,
+	}
+	for _, image := range images {
+		info, err := inspectImage(localImageName(image))
+		if err != nil {
+			return err
+		}
+		digest := digests[image]
+		if digest == "" {
+			digest = firstRepoDigest(info)
+		}
+		manifest.Images = append(manifest.Images, bundleImage{
+			Name:   image,
+			ID:     info.ID,
+			Digest: digest,
+		})
+	}
+
+	// 3. 使用 docker save 导出到临时文件
+	// 摘要引用导出后不带 RepoTags，导入后无法按名称找到，因此导出拉取时创建的本地标签
+	localNames := make([]string, 0, len(images))
+	for _, image := range images {
+		localNames = append(localNames, localImageName(image))
+	}
+	tmpFile, err := os.CreateTemp("", "cnfast-bundle-*.tar")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	fmt.Printf("正在导出 %d 个镜像...\n", len(images))
+	saveCmd := exec.Command("docker", append([]string{"save", "-o", tmpFile.Name()}, localNames...)...)
+	saveCmd.Stdout = os.Stdout
+	saveCmd.Stderr = os.Stderr
+	if err := saveCmd.Run(); err != nil {
+		return fmt.Errorf("导出镜像失败: %w", err)
+	}
+
+	manifest.ArchiveDigest, err = fileSHA256(tmpFile.Name())
+	if err != nil {
+		return err
+	}
+
+	// 4. 将清单与归档一起写入压缩包
+	if err := writeImageBundle(output, &manifest, tmpFile.Name()); err != nil {
+		os.Remove(output)
+		return fmt.Errorf("写入镜像包失败: %w", err)
+	}
+
+	fmt.Printf("✅ 镜像包已生成: %s\n", output)
+	for _, image := range manifest.Images {
+		fmt.Printf("  %s (%s)\n", image.Name, shortDigest(image.ID))
+	}
+	return nil
+}
+
+// writeImageBundle 写入 tar.gz 格式的离线镜像包
+// 清单文件写在最前面，导入时可以在解压归档前读取
+func writeImageBundle(output string, manifest *bundleManifest, archivePath string) error {
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化清单失败: %w", err)
+	}
+
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	archiveStat, err := archive.Stat()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	now := time.Now()
+
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Name:    bundleManifestName,
+		Mode:    0644,
+		Size:    int64(len(manifestData)),
+		ModTime: now,
+	}); err != nil {
+		return err
+	}
+	if _, err := tarWriter.Write(manifestData); err != nil {
+		return err
+	}
+
+	if err := tarWriter.WriteHeader(&tar.Header{
+		Name:    bundleArchiveName,
+		Mode:    0644,
+		Size:    archiveStat.Size(),
+		ModTime: now,
+	}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tarWriter, archive); err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// loadImageBundle 校验并导入离线镜像包
+// 该命令不需要网络，可在完全离线的服务器上执行
+func loadImageBundle(args []string) error {
+	if len(args) == 0 {
+		printBundleUsage()
+		return fmt.Errorf("缺少镜像包文件")
+	}
+
+	manifest, archivePath, archiveDigest, err := extractImageBundle(args[0])
+	if archivePath != "" {
+		defer os.Remove(archivePath)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 1. 导入前校验归档摘要，防止镜像包被篡改或损坏
+	if archiveDigest != manifest.ArchiveDigest {
+		return fmt.Errorf("镜像包校验失败，归档摘要不匹配 (期望 %s, 实际 %s)", manifest.ArchiveDigest, archiveDigest)
+	}
+	fmt.Printf("镜像包校验通过 (%s)\n", shortDigest(archiveDigest))
+
+	// 2. 导入镜像
+	loadCmd := exec.Command("docker", "load", "-i", archivePath)
+	loadCmd.Stdout = os.Stdout
+	loadCmd.Stderr = os.Stderr
+	if err := loadCmd.Run(); err != nil {
+		return fmt.Errorf("导入镜像失败: %w", err)
+	}
+
+	// 3. 导入后逐个校验镜像 ID，摘要引用的镜像按导出时的本地标签查找
+	failed := 0
+	for _, image := range manifest.Images {
+		info, err := inspectImage(localImageName(image.Name))
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("  ❌ %s: %v\n", image.Name, err)
+		case info.ID != image.ID:
+			failed++
+			fmt.Printf("  ❌ %s: 镜像 ID 不匹配 (期望 %s, 实际 %s)\n", image.Name, shortDigest(image.ID), shortDigest(info.ID))
+		default:
+			fmt.Printf("  ✅ %s (%s)\n", image.Name, shortDigest(info.ID))
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d 个镜像校验失败", failed)
+	}
+	fmt.Printf("\n✅ 已导入 %d 个镜像\n", len(manifest.Images))
+	return nil
+}
+
+// extractImageBundle 读取镜像包的清单，并将归档解压到临时文件
+// 返回: 清单、临时归档路径、归档实际摘要、错误
+func extractImageBundle(path string) (*bundleManifest, string, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("打开镜像包失败: %w", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("解压镜像包失败: %w", err)
+	}
+	defer gzipReader.Close()
+
+	var manifest *bundleManifest
+	var archivePath, archiveDigest string
+	tarReader := tar.NewReader(gzipReader)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, archivePath, "", fmt.Errorf("读取镜像包失败: %w", err)
+		}
+
+		switch header.Name {
+		case bundleManifestName:
+			manifest = &bundleManifest{}
+			if err := json.NewDecoder(tarReader).Decode(manifest); err != nil {
+				return nil, archivePath, "", fmt.Errorf("解析镜像包清单失败: %w", err)
+			}
+		case bundleArchiveName:
+			archivePath, archiveDigest, err = extractToTemp(tarReader)
+			if err != nil {
+				return nil, archivePath, "", err
+			}
+		}
+	}
+
+	if manifest == nil {
+		return nil, archivePath, "", fmt.Errorf("镜像包中缺少 %s", bundleManifestName)
+	}
+	if archivePath == "" {
+		return nil, "", "", fmt.Errorf("镜像包中缺少 %s", bundleArchiveName)
+	}
+
+	return manifest, archivePath, archiveDigest, nil
+}
+
+// extractToTemp 将数据流写入临时文件并同时计算 sha256 摘要
+func extractToTemp(reader io.Reader) (string, string, error) {
+	tmpFile, err := os.CreateTemp("", "cnfast-bundle-*.tar")
+	if err != nil {
+		return "", "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer tmpFile.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmpFile, hasher), reader); err != nil {
+		return tmpFile.Name(), "", fmt.Errorf("解压镜像归档失败: %w", err)
+	}
+
+	return tmpFile.Name(), "sha256:" + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// fileSHA256 计算文件的 sha256 摘要，格式为 sha256:<hex>
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("计算文件摘要失败: %w", err)
+	}
+	return "sha256:" + hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// firstRepoDigest 返回镜像第一个仓库摘要中的摘要部分
+func firstRepoDigest(info *imageInfo) string {
+	for _, repoDigest := range info.RepoDigests {
+		if idx := strings.LastIndex(repoDigest, "@"); idx >= 0 {
+			return repoDigest[idx+1:]
+		}
+	}
+	return ""
+}
+
+// shortDigest 返回便于显示的短摘要
+func shortDigest(digest string) string {
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if len(digest) > 12 {
+		digest = digest[:12]
+	}
+	return digest
+}
@@ -0,0 +1,248 @@
+// Package services 包含通过 Docker Engine API 操作本地镜像的逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/pkg/engine"
+	"cnfast/internal/pkg/util"
+
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// engineProgressInterval 输出整体下载进度的最小间隔
+const engineProgressInterval = 2 * time.Second
+
+var (
+	// dockerEngineClient 可用的 Engine API 客户端，不可用时为 nil
+	dockerEngineClient *engine.Client
+
+	// dockerEngineOnce 保证只探测一次 dockerd
+	dockerEngineOnce sync.Once
+)
+
+// dockerEngine 返回可用的 Engine API 客户端
+// 以下情况返回 nil，调用方应改用 docker 命令行：
+//   - 设置了 CNFAST_DOCKER_API=false
+//   - 使用了非默认的 docker context（命令行与默认 socket 可能指向不同的 dockerd）
+//   - DOCKER_HOST 协议不受支持或 dockerd 无法访问
+func dockerEngine() *engine.Client {
+	dockerEngineOnce.Do(func() {
+		if !config.DockerAPI || usesCustomDockerContext() {
+			return
+		}
+
+		client, err := engine.Available()
+		if err != nil {
+			if config.Debug {
+				fmt.Printf("Docker Engine API 不可用，使用 docker 命令行: %v\n", err)
+			}
+			return
+		}
+		dockerEngineClient = client
+	})
+	return dockerEngineClient
+}
+
+// usesCustomDockerContext 判断 docker 命令行是否使用了非默认的上下文
+// 设置了 DOCKER_HOST 时上下文不生效
+func usesCustomDockerContext() bool {
+	if os.Getenv("DOCKER_HOST") != "" {
+		return false
+	}
+	if name := os.Getenv("DOCKER_CONTEXT"); name != "" {
+		return name != "default"
+	}
+
+	cfg, err := loadDockerConfigFile()
+	if err != nil {
+		return true
+	}
+	return cfg.CurrentContext != "" && cfg.CurrentContext != "default"
+}
+
+// enginePullOptions 将 docker pull 选项转换为 Engine API 参数
+// 返回: 平台、是否静默、是否所有选项都能通过 Engine API 表达
+func enginePullOptions(pullFlags []string) (string, bool, bool) {
+	var platform string
+	var quiet bool
+	for i := 0; i < len(pullFlags); i++ {
+		switch flag := pullFlags[i]; {
+		case flag == "--platform" && i+1 < len(pullFlags):
+			platform = pullFlags[i+1]
+			i++
+		case strings.HasPrefix(flag, "--platform="):
+			platform = strings.TrimPrefix(flag, "--platform=")
+		case flag == "-q" || flag == "--quiet":
+			quiet = true
+		case flag == "--disable-content-trust" || flag == "--disable-content-trust=true":
+		default:
+			return "", false, false
+		}
+	}
+	return platform, quiet, true
+}
+
+// engineRegistryAuth 生成拉取加速镜像时使用的 X-Registry-Auth
+// 满足转发条件（见 forwardedCredential）时使用原始仓库的凭据，
+// 否则与 docker 命令行一致使用目标仓库自身的凭据
+// out: 警告信息的写入目标
+func engineRegistryAuth(original, accelerated string, out io.Writer) string {
+	host := imageDomain(accelerated)
+	if host == "" {
+		return ""
+	}
+
+	cred := forwardedCredential(original, accelerated, "pull")
+	if cred == nil {
+		var err error
+		if cred, err = lookupRegistryCredential(host); err != nil {
+			fmt.Fprintf(out, "警告: 读取 %s 的凭据失败: %v\n", host, err)
+		}
+	}
+	if cred == nil {
+		return ""
+	}
+
+	auth, err := engine.EncodeAuth(engine.AuthConfig{
+		Username:      cred.Username,
+		Password:      cred.Secret,
+		IdentityToken: cred.IdentityToken,
+		ServerAddress: host,
+	})
+	if err != nil {
+		return ""
+	}
+	return auth
+}
+
+// runImagePull 拉取加速镜像，dockerd 可通过 Engine API 访问时直接调用 API，否则执行 docker pull
+// original: 原始镜像名
+// accelerated: 实际拉取的镜像名
+// pullFlags: docker pull 选项
+// out: 进度输出
+func runImagePull(original, accelerated string, pullFlags []string, out io.Writer) error {
+	if client := dockerEngine(); client != nil {
+		if platform, quiet, ok := enginePullOptions(pullFlags); ok {
+			if config.Debug {
+				fmt.Fprintf(out, "通过 Docker Engine API 拉取: %s\n", accelerated)
+			}
+			progress := newPullProgress(out, quiet)
+			err := client.PullImage(context.Background(), accelerated, platform, engineRegistryAuth(original, accelerated, out), progress.handle)
+			progress.finish()
+			return err
+		}
+	}
+
+	args := append([]string{"pull"}, pullFlags...)
+	args = append(args, accelerated)
+
+	if config.Debug {
+		fmt.Fprintf(out, "执行命令: docker %s\n", strings.Join(args, " "))
+	}
+
+	cmd, cleanup := newAcceleratedDockerCmd(original, accelerated, args...)
+	defer cleanup()
+	cmd.Stdout = out
+	cmd.Stderr = out
+	return cmd.Run()
+}
+
+// pullProgress 根据 Engine API 的进度消息输出拉取进度
+// 每层只在状态变化时输出一行，下载与解压的字节进度汇总后定期输出
+type pullProgress struct {
+	// out 输出目标
+	out io.Writer
+
+	// quiet 是否只在结束时输出结果
+	quiet bool
+
+	// status 每层最近一次输出的状态
+	status map[string]string
+
+	// current、total 每层已下载与总字节数
+	current map[string]int64
+	total   map[string]int64
+
+	// lastReport 最近一次输出整体进度的时间
+	lastReport time.Time
+}
+
+// newPullProgress 创建进度输出
+func newPullProgress(out io.Writer, quiet bool) *pullProgress {
+	return &pullProgress{
+		out:     out,
+		quiet:   quiet,
+		status:  make(map[string]string),
+		current: make(map[string]int64),
+		total:   make(map[string]int64),
+	}
+}
+
+// handle 处理一条进度消息
+func (p *pullProgress) handle(msg *engine.JSONMessage) {
+	if p.quiet {
+		return
+	}
+
+	// 不带层 ID 的消息为整体状态，如 Digest: ...、Status: ...
+	if msg.ID == "" {
+		fmt.Fprintln(p.out, msg.Status)
+		return
+	}
+
+	switch msg.Status {
+	case "Downloading":
+		p.current[msg.ID] = msg.ProgressDetail.Current
+		p.total[msg.ID] = msg.ProgressDetail.Total
+		p.report()
+		if p.status[msg.ID] == msg.Status {
+			return
+		}
+	case "Extracting":
+		// 解压进度不计入下载量
+		if p.status[msg.ID] == msg.Status {
+			return
+		}
+	case "Download complete":
+		if total := p.total[msg.ID]; total > 0 {
+			p.current[msg.ID] = total
+		}
+	}
+
+	p.status[msg.ID] = msg.Status
+	fmt.Fprintf(p.out, "%s: %s\n", msg.ID, msg.Status)
+}
+
+// report 定期输出所有层的下载汇总
+func (p *pullProgress) report() {
+	if time.Since(p.lastReport) < engineProgressInterval {
+		return
+	}
+	p.lastReport = time.Now()
+
+	var current, total int64
+	for id, size := range p.total {
+		current += p.current[id]
+		total += size
+	}
+	fmt.Fprintf(p.out, "下载进度: %s / %s\n", util.FormatSize(current), util.FormatSize(total))
+}
+
+// finish 结束时输出汇总
+func (p *pullProgress) finish() {
+	if p.quiet || len(p.total) == 0 {
+		return
+	}
+
+	var total int64
+	for _, size := range p.total {
+		total += size
+	}
+	fmt.Fprintf(p.out, "共下载 %d 层, %s\n", len(p.total), util.FormatSize(total))
+}
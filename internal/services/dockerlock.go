@@ -0,0 +1,287 @@
+// Package services 包含镜像摘要锁文件的生成与校验逻辑
+package services
+
+import (
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/registry"
+	"cnfast/internal/pkg/util"
+
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 镜像摘要来源
+const (
+	// lockSourceUpstream 从原始仓库解析
+	lockSourceUpstream = "upstream"
+
+	// lockSourceAccelerator 原始仓库不可达，经加速服务解析
+	lockSourceAccelerator = "accelerator"
+
+	// lockSourcePinned 镜像引用中已指定摘要
+	lockSourcePinned = "pinned"
+)
+
+// defaultLockFile 默认锁文件名，拉取时在当前目录查找
+const defaultLockFile = "cnfast.lock"
+
+// imageLockFile 镜像摘要锁文件
+// 记录每个镜像的规范清单摘要，拉取时用于校验加速服务返回的镜像未被篡改
+type imageLockFile struct {
+	// Version 锁文件格式版本
+	Version int `json:"version"`
+
+	// GeneratedAt 生成时间
+	GeneratedAt string `json:"generatedAt"`
+
+	// Images 锁定的镜像，按镜像名排序
+	Images []imageLock `json:"images"`
+}
+
+// imageLock 单个镜像的锁定信息
+type imageLock struct {
+	// Image 镜像名（与 compose 文件或镜像列表中的写法一致）
+	Image string `json:"image"`
+
+	// Digest 清单摘要（多平台镜像为清单列表的摘要）
+	Digest string `json:"digest"`
+
+	// Source 摘要来源: upstream、accelerator 或 pinned
+	Source string `json:"source"`
+}
+
+// activeImageLock 当前目录的锁文件，不存在时为 nil
+var activeImageLock *imageLockFile
+
+// find 查找镜像的锁定信息，镜像名按规范化引用比较
+func (l *imageLockFile) find(image string) *imageLock {
+	if l == nil {
+		return nil
+	}
+	key := canonicalImage(image)
+	for i := range l.Images {
+		if canonicalImage(l.Images[i].Image) == key {
+			return &l.Images[i]
+		}
+	}
+	return nil
+}
+
+// loadImageLockFile 读取锁文件，文件不存在时返回 nil
+func loadImageLockFile(path string) (*imageLockFile, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取锁文件失败: %w", err)
+	}
+
+	lock := &imageLockFile{}
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("解析锁文件 %s 失败: %w", path, err)
+	}
+	return lock, nil
+}
+
+// useImageLock 加载当前目录的锁文件，之后的拉取都会按锁文件校验
+// 锁文件存在但无法解析时直接退出，避免在未校验的情况下继续拉取
+func useImageLock() {
+	lock, err := loadImageLockFile(defaultLockFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if lock != nil {
+		fmt.Printf("使用锁文件 %s 校验镜像摘要（%d 个镜像）\n", defaultLockFile, len(lock.Images))
+	}
+	activeImageLock = lock
+}
+
+// verifyLockedImage 校验拉取到的镜像摘要是否与锁文件一致
+// 不一致时删除拉取到的镜像标签并返回错误；锁文件中没有该镜像时不校验
+// original: 原始镜像名
+// pulled: 实际拉取的镜像名（可能带加速域名）
+// out: 删除标签时警告信息的写入目标
+func verifyLockedImage(original, pulled string, out io.Writer) error {
+	locked := activeImageLock.find(original)
+	if locked == nil {
+		return nil
+	}
+
+	info, err := inspectImage(pulled)
+	if err != nil {
+		return fmt.Errorf("校验镜像摘要失败: %w", err)
+	}
+
+	// 镜像 ID 由内容决定，任一仓库摘要与锁定值一致即说明内容相同
+	for _, repoDigest := range info.RepoDigests {
+		if strings.HasSuffix(repoDigest, "@"+locked.Digest) {
+			return nil
+		}
+	}
+
+	removeImageTag(pulled, out)
+
+	actual := firstRepoDigest(info)
+	if actual == "" {
+		actual = "未知"
+	}
+	return fmt.Errorf("镜像摘要与锁文件不一致，已删除拉取的镜像（期望 %s，实际 %s）", locked.Digest, actual)
+}
+
+// dockerLockImages 处理 cnfast docker lock 命令
+// 解析 compose 文件或镜像列表中每个镜像的摘要并写入锁文件
+// args: lock 之后的全部参数
+// proxyList: 按优先顺序排列的代理列表，原始仓库不可达时经第一个代理解析
+func dockerLockImages(args []string, proxyList []models.ProxyItem) {
+	output, args, hasOutput := util.ExtractFlagValue(args, "-o", "--output")
+	if !hasOutput {
+		output = defaultLockFile
+	}
+	listFile, args, hasList := util.ExtractFlagValue(args, "-f", "--file")
+	composeFile, args, hasCompose := util.ExtractFlagValue(args, "-c", "--compose")
+
+	var images []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			fmt.Fprintf(os.Stderr, "错误: 不支持的选项 '%s'\n", arg)
+			printLockUsage()
+			os.Exit(1)
+		}
+		images = append(images, arg)
+	}
+
+	if hasList {
+		fileImages, err := readImageListFile(listFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		images = append(images, fileImages...)
+	}
+
+	// 未指定任何镜像时使用当前目录的 compose 文件
+	if !hasCompose && len(images) == 0 {
+		composeFile = findComposeFile()
+		hasCompose = composeFile != ""
+	}
+	if hasCompose {
+		composeImages, err := loadComposeImages(composeFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		for _, item := range composeImages {
+			images = append(images, item.Image)
+		}
+	}
+
+	images = uniqueImages(images)
+	if len(images) == 0 {
+		fmt.Fprintln(os.Stderr, "错误: 未找到需要锁定的镜像")
+		printLockUsage()
+		os.Exit(1)
+	}
+
+	if len(proxyList) > 0 {
+		useDockerProxy(&proxyList[0])
+	}
+
+	lock := &imageLockFile{
+		Version:     1,
+		GeneratedAt: time.Now().Format(time.RFC3339),
+	}
+
+	failed := 0
+	for _, image := range images {
+		digest, source, err := resolveImageDigest(image)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", image, err)
+			failed++
+			continue
+		}
+
+		fmt.Printf("✅ %s -> %s\n", image, digest)
+		if source == lockSourceAccelerator {
+			fmt.Println("   警告: 原始仓库不可达，摘要经加速服务解析，请确认加速服务可信")
+		}
+		lock.Images = append(lock.Images, imageLock{Image: image, Digest: digest, Source: source})
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "错误: %d 个镜像解析失败，未写入锁文件\n", failed)
+		os.Exit(1)
+	}
+
+	sort.Slice(lock.Images, func(i, j int) bool {
+		return lock.Images[i].Image < lock.Images[j].Image
+	})
+
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 生成锁文件失败: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(output, append(data, '\n'), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 写入锁文件失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("已写入锁文件 %s（%d 个镜像）\n", output, len(lock.Images))
+}
+
+// resolveImageDigest 解析镜像的清单摘要
+// 优先访问原始仓库；原始仓库不可达时经当前加速域名解析
+// 返回: 摘要、摘要来源、错误
+func resolveImageDigest(image string) (string, string, error) {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return "", "", err
+	}
+	if ref.Digest != "" {
+		return ref.Digest, lockSourcePinned, nil
+	}
+
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+
+	client := registry.NewClient(registryAuthFunc(image))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	digest, upstreamErr := client.ResolveDigest(ctx, registry.APIHost(ref.Domain), ref.Path, tag)
+	if upstreamErr == nil {
+		return digest, lockSourceUpstream, nil
+	}
+
+	accelerated := replaceImageWithSpecificDomain(image)
+	if accelerated == image {
+		return "", "", upstreamErr
+	}
+	accelRef, err := reference.Parse(accelerated)
+	if err != nil {
+		return "", "", upstreamErr
+	}
+
+	digest, err = client.ResolveDigest(ctx, accelRef.Domain, accelRef.Path, tag)
+	if err != nil {
+		return "", "", fmt.Errorf("原始仓库: %v; 加速服务: %v", upstreamErr, err)
+	}
+	return digest, lockSourceAccelerator, nil
+}
+
+// printLockUsage 输出 lock 命令用法
+func printLockUsage() {
+	fmt.Fprintln(os.Stderr, "用法: cnfast docker lock [镜像...] [-f 镜像列表文件] [-c compose 文件] [-o 输出文件]")
+	fmt.Fprintln(os.Stderr, "未指定镜像时读取当前目录的 compose 文件，默认输出到 cnfast.lock")
+}
@@ -0,0 +1,346 @@
+// Package services 包含多平台镜像拉取逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/pkg/reference"
+
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// platformRecordFile 本地镜像平台记录文件名（位于 config.HomeDir）
+const platformRecordFile = "platforms.json"
+
+// platformRecord 记录一个本地镜像对应的平台
+type platformRecord struct {
+	// Image 本地镜像名
+	Image string `json:"image"`
+
+	// Source 原始镜像名
+	Source string `json:"source"`
+
+	// Platform 镜像平台，如 linux/arm64
+	Platform string `json:"platform"`
+
+	// ID 本地镜像 ID
+	ID string `json:"id"`
+
+	// Digest 该平台在清单列表中的摘要
+	Digest string `json:"digest,omitempty"`
+
+	// PulledAt 拉取时间
+	PulledAt string `json:"pulledAt"`
+}
+
+// remoteManifestList docker manifest inspect 返回的清单列表（仅包含需要的字段）
+type remoteManifestList struct {
+	// MediaType 清单类型
+	MediaType string `json:"mediaType"`
+
+	// Manifests 各平台的清单
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+			Variant      string `json:"variant"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// splitPlatforms 解析逗号分隔的平台列表
+func splitPlatforms(value string) []string {
+	var platforms []string
+	for _, platform := range strings.Split(value, ",") {
+		platform = strings.TrimSpace(platform)
+		if platform != "" {
+			platforms = append(platforms, platform)
+		}
+	}
+	return uniqueStrings(platforms)
+}
+
+// platformMatches 判断清单中的平台是否满足请求的平台
+// 请求中未指定 variant 时匹配任意 variant
+func platformMatches(requested, osName, arch, variant string) bool {
+	parts := strings.Split(requested, "/")
+	if len(parts) < 2 || parts[0] != osName || parts[1] != arch {
+		return false
+	}
+	return len(parts) < 3 || parts[2] == variant
+}
+
+// platformSuffix 将平台转换为标签后缀，如 linux/arm64/v8 -> linux-arm64-v8
+func platformSuffix(platform string) string {
+	return strings.ReplaceAll(platform, "/", "-")
+}
+
+// withTagSuffix 为镜像标签追加后缀，未指定标签时以 latest 为基础
+// 例如 nginx:1.25 -> nginx:1.25-linux-arm64
+func withTagSuffix(image, suffix string) string {
+	ref, err := reference.Parse(localImageName(image))
+	if err != nil {
+		return image + "-" + suffix
+	}
+
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+	return ref.FamiliarName() + ":" + tag + "-" + suffix
+}
+
+// inspectRemotePlatforms 通过加速域名查询镜像清单列表中的平台摘要
+// 返回: 平台 -> 摘要 的映射、清单中缺失的平台、查询错误；
+// 镜像不是多平台清单时映射与缺失列表均为空
+func inspectRemotePlatforms(original, accelerated string, platforms []string) (map[string]string, []string, error) {
+	cmd, cleanup := newAcceleratedDockerCmd(original, accelerated, "manifest", "inspect", accelerated)
+	defer cleanup()
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询镜像清单失败: %w", err)
+	}
+
+	var list remoteManifestList
+	if err := json.Unmarshal(output, &list); err != nil {
+		return nil, nil, fmt.Errorf("解析镜像清单失败: %w", err)
+	}
+	if len(list.Manifests) == 0 {
+		return nil, nil, nil
+	}
+
+	digests := make(map[string]string, len(platforms))
+	var missing []string
+	for _, platform := range platforms {
+		for _, manifest := range list.Manifests {
+			p := manifest.Platform
+			if platformMatches(platform, p.OS, p.Architecture, p.Variant) {
+				digests[platform] = manifest.Digest
+				break
+			}
+		}
+		if _, ok := digests[platform]; !ok {
+			missing = append(missing, platform)
+		}
+	}
+
+	return digests, missing, nil
+}
+
+// dockerHostPlatform 返回 dockerd 运行的平台，如 linux/amd64
+// 无法查询时按本机 CPU 架构推断为 linux/<arch>
+func dockerHostPlatform() string {
+	if client := dockerEngine(); client != nil {
+		if platform, err := client.ServerPlatform(context.Background()); err == nil {
+			return platform
+		}
+	}
+	output, err := exec.Command("docker", "version", "--format", "{{.Server.Os}}/{{.Server.Arch}}").Output()
+	if platform := strings.TrimSpace(string(output)); err == nil && strings.Count(platform, "/") == 1 {
+		return platform
+	}
+	return "linux/" + runtime.GOARCH
+}
+
+// matchesHostPlatform 判断请求的平台是否为 dockerd 运行的平台
+// dockerd 不报告 variant，因此只比较操作系统与架构
+func matchesHostPlatform(requested, hostPlatform string) bool {
+	parts := strings.SplitN(requested, "/", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	variant := ""
+	if len(parts) == 3 {
+		variant = parts[2]
+	}
+	return platformMatches(hostPlatform, parts[0], parts[1], variant)
+}
+
+// pullImagesForPlatforms 按平台拉取镜像，并记录每个本地镜像对应的平台
+// 拉取多个平台或指定了 platformTag 时，各平台使用平台后缀标签区分，
+// 原始标签只指向与 dockerd 平台一致的镜像；拉取单个平台时直接使用原始标签
+func pullImagesForPlatforms(images, platforms, pullFlags []string, concurrency int, platformTag bool) []pullResult {
+	hostPlatform := ""
+	if len(platforms) > 1 || platformTag {
+		hostPlatform = dockerHostPlatform()
+		fmt.Printf("提示: 各平台镜像使用平台后缀标签（<标签>-<平台>），原始标签指向本机平台 %s\n", hostPlatform)
+	}
+
+	var (
+		mu      sync.Mutex
+		records []platformRecord
+	)
+
+	results := runPullJobs(images, concurrency, func(image string, out io.Writer) error {
+		imageRecords, err := pullImagePlatforms(image, platforms, pullFlags, hostPlatform, out)
+
+		mu.Lock()
+		records = append(records, imageRecords...)
+		mu.Unlock()
+
+		return err
+	})
+
+	if len(records) > 0 {
+		if err := savePlatformRecords(records); err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 保存平台记录失败: %v\n", err)
+		}
+		fmt.Println("\n平台记录:")
+		for _, record := range records {
+			fmt.Printf("  %-50s %-16s %s\n", record.Image, record.Platform, shortDigest(record.ID))
+		}
+	}
+
+	return results
+}
+
+// pullImagePlatforms 依次拉取单个镜像的各个平台
+// 同一镜像的不同平台共享加速标签，因此必须串行拉取
+// hostPlatform: dockerd 运行的平台；为空时只拉取一个平台并使用原始标签，
+// 否则各平台使用平台后缀标签，原始标签指向与其一致的平台
+func pullImagePlatforms(original string, platforms, pullFlags []string, hostPlatform string, out io.Writer) ([]platformRecord, error) {
+	accelerated := replaceImageWithSpecificDomain(original)
+	if accelerated != original {
+		fmt.Fprintf(out, "镜像加速: %s -> %s\n", original, accelerated)
+	}
+
+	// 先检查清单列表，提前发现不支持的平台
+	digests, missing, err := inspectRemotePlatforms(original, accelerated, platforms)
+	if err != nil {
+		fmt.Fprintf(out, "警告: %v，将直接按平台拉取\n", err)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("镜像不支持平台: %s", strings.Join(missing, ", "))
+	}
+
+	localName := localImageName(original)
+	var records []platformRecord
+	var hostRecord *platformRecord
+	for _, platform := range platforms {
+		flags := append([]string{"--platform", platform}, pullFlags...)
+		if err := runImagePull(original, accelerated, flags, out); err != nil {
+			return records, fmt.Errorf("拉取平台 %s 失败: %w", platform, err)
+		}
+
+		if err := verifyLockedImage(original, accelerated, out); err != nil {
+			return records, err
+		}
+
+		info, err := inspectImage(accelerated)
+		if err != nil {
+			return records, err
+		}
+
+		record := platformRecord{
+			Image:    original,
+			Source:   original,
+			Platform: platform,
+			ID:       info.ID,
+			Digest:   digests[platform],
+			PulledAt: time.Now().Format(time.RFC3339),
+		}
+
+		if hostPlatform == "" {
+			if localName != accelerated {
+				retagImage(accelerated, original, out)
+			}
+			records = append(records, record)
+			continue
+		}
+
+		// 加速标签（未加速时即原始标签）会被下一个平台覆盖，先打上平台后缀标签
+		record.Image = withTagSuffix(original, platformSuffix(platform))
+		if err := tagImage(accelerated, record.Image); err != nil {
+			return records, fmt.Errorf("为平台 %s 打标签失败: %w", platform, err)
+		}
+		records = append(records, record)
+
+		if hostRecord == nil && matchesHostPlatform(platform, hostPlatform) {
+			host := record
+			host.Image = localName
+			hostRecord = &host
+		}
+	}
+
+	if hostPlatform == "" {
+		return records, nil
+	}
+
+	// 原始标签指向本机平台的镜像；没有拉取本机平台时不保留原始标签，避免指向无法运行的镜像
+	switch {
+	case hostRecord != nil:
+		if err := tagImage(hostRecord.ID, localName); err != nil {
+			fmt.Fprintf(out, "警告: 为本机平台打原始标签失败: %v\n", err)
+		} else {
+			records = append(records, *hostRecord)
+		}
+	case accelerated == localName:
+		removeImageTag(localName, out)
+	default:
+		fmt.Fprintf(out, "未拉取本机平台 %s，不创建原始标签 %s\n", hostPlatform, localName)
+	}
+	if accelerated != localName {
+		removeImageTag(accelerated, out)
+	}
+
+	return records, nil
+}
+
+// loadPlatformRecords 读取本地镜像平台记录
+func loadPlatformRecords() ([]platformRecord, error) {
+	data, err := os.ReadFile(filepath.Join(config.HomeDir, platformRecordFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []platformRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("解析平台记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// savePlatformRecords 合并并保存平台记录，同一本地镜像名与平台只保留最新的记录
+func savePlatformRecords(newRecords []platformRecord) error {
+	records, err := loadPlatformRecords()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range newRecords {
+		replaced := false
+		for i := range records {
+			if records[i].Image == record.Image && records[i].Platform == record.Platform {
+				records[i] = record
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			records = append(records, record)
+		}
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.HomeDir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(config.HomeDir, platformRecordFile), data, 0644)
+}
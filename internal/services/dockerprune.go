@@ -0,0 +1,251 @@
+// Package services 包含残留加速标签的清理逻辑
+package services
+
+import (
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/util"
+
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// accelPattern 加速仓库名到原始仓库的反向映射
+type accelPattern struct {
+	// Registry 原始镜像源域名
+	Registry string
+
+	// Prefix 加速仓库名中仓库路径之前的部分
+	Prefix string
+
+	// Suffix 加速仓库名中仓库路径之后的部分（仅完整地址模板可能存在）
+	Suffix string
+}
+
+// accelAlias 本地残留的加速标签
+type accelAlias struct {
+	// Alias 带加速域名的镜像引用
+	Alias string
+
+	// Original 对应的原始镜像名
+	Original string
+}
+
+// buildAccelPatterns 根据全部已知代理生成反向映射
+// 包括内置加速域名与代理服务下发的映射，按前缀长度降序排列，优先匹配更具体的前缀
+func buildAccelPatterns(proxyList []models.ProxyItem) []accelPattern {
+	mappings := []map[string]string{buildRegistryMapping(defaultAccelDomain, nil, "")}
+	for _, proxy := range proxyList {
+		mappings = append(mappings, buildRegistryMapping(proxy.ProxyUrl, proxy.RegistryMapping, proxy.ImageTemplate))
+	}
+
+	seen := make(map[accelPattern]bool)
+	var patterns []accelPattern
+	for _, mapping := range mappings {
+		for registry, accel := range mapping {
+			pattern := accelPattern{Registry: registry, Prefix: accel + "/"}
+			if idx := strings.Index(accel, "{path}"); idx >= 0 {
+				pattern.Prefix = accel[:idx]
+				pattern.Suffix = accel[idx+len("{path}"):]
+			}
+			if !seen[pattern] {
+				seen[pattern] = true
+				patterns = append(patterns, pattern)
+			}
+		}
+	}
+
+	// docker.io 与 registry-1.docker.io 映射相同时按名称排序优先还原为 docker.io
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i].Prefix) != len(patterns[j].Prefix) {
+			return len(patterns[i].Prefix) > len(patterns[j].Prefix)
+		}
+		return patterns[i].Registry < patterns[j].Registry
+	})
+	return patterns
+}
+
+// originalRepository 将加速仓库名还原为原始仓库名，不匹配任何加速域名时返回 false
+func originalRepository(repository string, patterns []accelPattern) (string, bool) {
+	for _, pattern := range patterns {
+		if !strings.HasPrefix(repository, pattern.Prefix) || !strings.HasSuffix(repository, pattern.Suffix) {
+			continue
+		}
+		path := strings.TrimSuffix(strings.TrimPrefix(repository, pattern.Prefix), pattern.Suffix)
+		if path == "" {
+			continue
+		}
+
+		ref, err := reference.Parse(pattern.Registry + "/" + path)
+		if err != nil {
+			continue
+		}
+		if ref.Domain == "registry-1.docker.io" {
+			ref.Domain = reference.DefaultDomain
+		}
+		return ref.FamiliarName(), true
+	}
+	return "", false
+}
+
+// localImageRef 本地镜像的一个引用
+type localImageRef struct {
+	// Repository 仓库名
+	Repository string
+
+	// Tag 标签，没有标签时为 <none>
+	Tag string
+
+	// Digest 仓库摘要，没有时为 <none>
+	Digest string
+}
+
+// listLocalImageRefs 列出本地镜像的全部引用，优先使用 Engine API
+// 有标签的镜像按标签列出，没有标签的镜像按仓库摘要列出
+func listLocalImageRefs() ([]localImageRef, error) {
+	client := dockerEngine()
+	if client == nil {
+		return listLocalImageRefsCLI()
+	}
+
+	images, err := client.ListImages(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("列出本地镜像失败: %w", err)
+	}
+
+	var refs []localImageRef
+	for _, image := range images {
+		tagged := false
+		for _, repoTag := range image.RepoTags {
+			if repoTag == "<none>:<none>" {
+				continue
+			}
+			i := strings.LastIndex(repoTag, ":")
+			if i < 0 {
+				continue
+			}
+			refs = append(refs, localImageRef{Repository: repoTag[:i], Tag: repoTag[i+1:], Digest: "<none>"})
+			tagged = true
+		}
+		if tagged {
+			continue
+		}
+		for _, repoDigest := range image.RepoDigests {
+			if parts := strings.SplitN(repoDigest, "@", 2); len(parts) == 2 {
+				refs = append(refs, localImageRef{Repository: parts[0], Tag: "<none>", Digest: parts[1]})
+			}
+		}
+	}
+	return refs, nil
+}
+
+// listLocalImageRefsCLI 通过 docker image ls 列出本地镜像的全部引用
+func listLocalImageRefsCLI() ([]localImageRef, error) {
+	cmd := exec.Command("docker", "image", "ls", "--digests", "--format", "{{.Repository}}\t{{.Tag}}\t{{.Digest}}")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("列出本地镜像失败: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var refs []localImageRef
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			continue
+		}
+		refs = append(refs, localImageRef{Repository: fields[0], Tag: fields[1], Digest: fields[2]})
+	}
+	return refs, nil
+}
+
+// findAccelAliases 查找本地带加速域名的镜像标签
+func findAccelAliases(patterns []accelPattern) ([]accelAlias, error) {
+	refs, err := listLocalImageRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	var aliases []accelAlias
+	for _, ref := range refs {
+		original, ok := originalRepository(ref.Repository, patterns)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case ref.Tag != "<none>":
+			aliases = append(aliases, accelAlias{Alias: ref.Repository + ":" + ref.Tag, Original: original + ":" + ref.Tag})
+		case ref.Digest != "<none>":
+			// 按摘要拉取的镜像没有标签，恢复为 cnfast 拉取时使用的本地名称
+			aliases = append(aliases, accelAlias{Alias: ref.Repository + "@" + ref.Digest, Original: localImageName(original + "@" + ref.Digest)})
+		}
+	}
+	return aliases, nil
+}
+
+// DockerPruneAccel 处理 cnfast docker prune-accel 命令
+// 查找拉取中断或重新打标签失败后残留的加速标签，原始标签缺失时先恢复，再删除加速标签
+// args: prune-accel 之后的全部参数
+// proxyList: 已知的代理列表，用于识别代理服务下发的加速域名
+func DockerPruneAccel(args []string, proxyList []models.ProxyItem) {
+	dryRun, args := util.ExtractBoolFlag(args, "--dry-run", "-n")
+	if len(args) > 0 {
+		fmt.Fprintln(os.Stderr, "用法: cnfast docker prune-accel [--dry-run]")
+		os.Exit(1)
+	}
+
+	aliases, err := findAccelAliases(buildAccelPatterns(proxyList))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+	if len(aliases) == 0 {
+		fmt.Println("未发现残留的加速标签")
+		return
+	}
+
+	failed := 0
+	for _, alias := range aliases {
+		// 原始标签已存在时（即使指向其他镜像）保留原样，只删除加速标签
+		_, err := inspectImage(alias.Original)
+		restore := err != nil
+
+		action := "删除"
+		if restore {
+			action = "恢复标签并删除"
+		}
+		fmt.Printf("%s: %s -> %s\n", action, alias.Alias, alias.Original)
+		if dryRun {
+			continue
+		}
+
+		if restore {
+			if err := tagImage(alias.Alias, alias.Original); err != nil {
+				fmt.Fprintf(os.Stderr, "  ❌ 恢复标签失败: %v\n", err)
+				failed++
+				continue
+			}
+		}
+		if err := untagImage(alias.Alias); err != nil {
+			fmt.Fprintf(os.Stderr, "  ❌ 删除加速标签失败: %v\n", err)
+			failed++
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("\n共 %d 个加速标签（--dry-run 未做任何修改）\n", len(aliases))
+		return
+	}
+
+	fmt.Printf("\n清理完成: 成功 %d 个, 失败 %d 个\n", len(aliases)-failed, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,413 @@
+// Package services 包含 Docker 镜像批量拉取逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/util"
+
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// pullResult 单个镜像的拉取结果
+type pullResult struct {
+	// Image 原始镜像名
+	Image string
+
+	// Err 拉取失败时的错误，成功为 nil
+	Err error
+}
+
+// dockerPullValueFlags docker pull 中需要携带参数值的选项
+var dockerPullValueFlags = []string{"--platform"}
+
+// parsePullArgs 解析 cnfast docker pull 的参数
+// args: pull 之后的全部参数
+// 返回: 待拉取的镜像列表、透传给 docker pull 的选项、并发数、错误
+func parsePullArgs(args []string) ([]string, []string, int, error) {
+	concurrency := config.PullConcurrency
+
+	// 提取 cnfast 自有选项
+	listFile, args, hasFile := util.ExtractFlagValue(args, "-f", "--file")
+	parallel, args, hasParallel := util.ExtractFlagValue(args, "-j", "--parallel")
+
+	if hasParallel {
+		n, err := strconv.Atoi(parallel)
+		if err != nil || n < 1 {
+			return nil, nil, 0, fmt.Errorf("无效的并发数: %s", parallel)
+		}
+		concurrency = n
+	}
+
+	var images []string
+	var pullFlags []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			images = append(images, arg)
+			continue
+		}
+
+		pullFlags = append(pullFlags, arg)
+		// 带值选项需要把下一个参数一并透传
+		if isCommandSupported(arg, dockerPullValueFlags) && i+1 < len(args) {
+			pullFlags = append(pullFlags, args[i+1])
+			i++
+		}
+	}
+
+	if hasFile {
+		fileImages, err := readImageListFile(listFile)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		images = append(images, fileImages...)
+	}
+
+	images = uniqueImages(images)
+	if len(images) == 0 {
+		return nil, nil, 0, fmt.Errorf("未指定需要拉取的镜像")
+	}
+
+	return images, pullFlags, concurrency, nil
+}
+
+// readImageListFile 读取镜像列表文件
+// 每行一个镜像，支持 # 开头的注释和行尾注释，空行会被忽略
+func readImageListFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开镜像列表文件失败: %w", err)
+	}
+	defer file.Close()
+
+	var images []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		images = append(images, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取镜像列表文件失败: %w", err)
+	}
+
+	return images, nil
+}
+
+// uniqueStrings 去除重复项并保持原有顺序
+func uniqueStrings(items []string) []string {
+	seen := make(map[string]bool, len(items))
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if seen[item] {
+			continue
+		}
+		seen[item] = true
+		result = append(result, item)
+	}
+	return result
+}
+
+// canonicalImage 返回镜像的规范引用，用于比较不同写法的同一镜像
+// 例如 nginx、nginx:latest 与 docker.io/library/nginx:latest 的结果相同；无法解析时原样返回
+func canonicalImage(image string) string {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return image
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref.String()
+}
+
+// uniqueImages 按规范引用去除重复的镜像，保留第一次出现的写法
+func uniqueImages(images []string) []string {
+	seen := make(map[string]bool, len(images))
+	result := make([]string, 0, len(images))
+	for _, image := range images {
+		key := canonicalImage(image)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, image)
+	}
+	return result
+}
+
+// pullImage 通过加速域名拉取单个镜像，并重新打标签为原始名称
+// original: 原始镜像名
+// pullFlags: 透传给 docker pull 的选项
+// out: docker 命令输出的写入目标
+func pullImage(original string, pullFlags []string, out io.Writer) error {
+	_, err := pullImageDigest(original, pullFlags, out)
+	return err
+}
+
+// pullImageDigest 与 pullImage 相同，并返回拉取到的仓库清单摘要
+// 加速标签删除后本地镜像不再带有该仓库摘要，因此在重新打标签之前读取
+// 返回: 仓库清单摘要（无法获取时为空）、错误
+func pullImageDigest(original string, pullFlags []string, out io.Writer) (string, error) {
+	accelerated := replaceImageWithSpecificDomain(original)
+	if accelerated != original {
+		fmt.Fprintf(out, "镜像加速: %s -> %s\n", original, accelerated)
+	}
+
+	if err := runImagePull(original, accelerated, pullFlags, out); err != nil {
+		return "", fmt.Errorf("拉取镜像失败: %w", err)
+	}
+
+	if err := verifyLockedImage(original, accelerated, out); err != nil {
+		return "", err
+	}
+
+	var digest string
+	if ref, err := reference.Parse(original); err == nil && ref.Digest != "" {
+		digest = ref.Digest
+	} else if info, err := inspectImage(accelerated); err == nil {
+		digest = firstRepoDigest(info)
+	}
+
+	if accelerated != original {
+		retagImage(accelerated, original, out)
+	}
+	return digest, nil
+}
+
+// pullImages 以有限并发批量拉取镜像
+func pullImages(images []string, pullFlags []string, concurrency int) []pullResult {
+	return runPullJobs(images, concurrency, func(image string, out io.Writer) error {
+		return pullImage(image, pullFlags, out)
+	})
+}
+
+// runPullJobs 以有限并发对每个镜像执行拉取任务，见 runJobs
+func runPullJobs(images []string, concurrency int, job func(image string, out io.Writer) error) []pullResult {
+	return runJobs(images, concurrency, "拉取", job)
+}
+
+// runJobs 以有限并发对每个镜像执行任务
+// action: 任务名称，用于输出，如 "拉取"、"同步"
+// 单个镜像时直接输出 docker 的进度信息；多个镜像时缓存各自的输出，
+// 仅在失败或调试模式下打印，避免多个进度条交错；
+// 使用同一加速镜像名的任务共享本地的加速标签，会在同一个协程中依次执行
+func runJobs(images []string, concurrency int, action string, job func(image string, out io.Writer) error) []pullResult {
+	results := make([]pullResult, len(images))
+
+	if len(images) == 1 {
+		results[0] = pullResult{Image: images[0], Err: job(images[0], os.Stdout)}
+		return results
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	fmt.Printf("开始%s %d 个镜像（并发数: %d）\n", action, len(images), concurrency)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		finished int
+	)
+	sem := make(chan struct{}, concurrency)
+
+	for _, group := range groupPullJobs(images) {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(group []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			for _, i := range group {
+				image := images[i]
+				var buf bytes.Buffer
+				err := job(image, &buf)
+				results[i] = pullResult{Image: image, Err: err}
+
+				mu.Lock()
+				finished++
+				if err != nil {
+					fmt.Printf("[%d/%d] ❌ %s\n", finished, len(images), image)
+					fmt.Print(indentOutput(buf.String()))
+				} else {
+					fmt.Printf("[%d/%d] ✅ %s\n", finished, len(images), image)
+					if config.Debug {
+						fmt.Print(indentOutput(buf.String()))
+					}
+				}
+				mu.Unlock()
+			}
+		}(group)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// groupPullJobs 按实际拉取的加速镜像名对任务分组，返回各组任务的下标
+// 例如 nginx:1.25@sha256:... 与 nginx@sha256:... 都拉取同一个加速引用，
+// 并发执行时一个任务重新打标签后会删除另一个任务仍在使用的加速标签
+func groupPullJobs(images []string) [][]int {
+	index := make(map[string]int, len(images))
+	var groups [][]int
+	for i, image := range images {
+		// 同步任务等不是镜像名的任务各自成组
+		key := image
+		if _, err := reference.Parse(image); err == nil {
+			key = canonicalImage(replaceImageWithSpecificDomain(image))
+		}
+		if n, ok := index[key]; ok {
+			groups[n] = append(groups[n], i)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []int{i})
+	}
+	return groups
+}
+
+// indentOutput 为命令输出的每一行添加缩进，便于与进度信息区分
+func indentOutput(output string) string {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return ""
+	}
+	return "    " + strings.ReplaceAll(output, "\n", "\n    ") + "\n"
+}
+
+// printPullReport 输出批量拉取的汇总结果
+// 返回失败的镜像数量
+func printPullReport(results []pullResult) int {
+	return printJobReport(results, "拉取")
+}
+
+// printJobReport 输出批量任务的汇总结果
+// action: 任务名称，如 "拉取"、"同步"
+// 返回失败的镜像数量
+func printJobReport(results []pullResult, action string) int {
+	var failed []pullResult
+	for _, result := range results {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	if len(results) == 1 {
+		if len(failed) == 1 {
+			fmt.Fprintf(os.Stderr, "命令执行失败: %v\n", failed[0].Err)
+		}
+		return len(failed)
+	}
+
+	fmt.Printf("\n%s完成: 成功 %d 个, 失败 %d 个\n", action, len(results)-len(failed), len(failed))
+	for _, result := range failed {
+		fmt.Printf("  ❌ %s: %v\n", result.Image, result.Err)
+	}
+	return len(failed)
+}
+
+// pullWithFailover 依次使用 docker 代理拉取镜像
+// 每次切换代理后只重试尚未成功的镜像，全部代理失败后按直连策略处理
+// images: 待拉取的镜像
+// proxyList: 按优先顺序排列的代理列表
+// pull: 使用当前代理拉取指定镜像的函数
+func pullWithFailover(images []string, proxyList []models.ProxyItem, pull func(pending []string) []pullResult) []pullResult {
+	return jobsWithFailover(images, proxyList, "拉取", pull)
+}
+
+// jobsWithFailover 依次使用 docker 代理执行批量任务，见 pullWithFailover
+// action: 任务名称，如 "拉取"、"同步"
+func jobsWithFailover(images []string, proxyList []models.ProxyItem, action string, pull func(pending []string) []pullResult) []pullResult {
+	errs := make(map[string]error, len(images))
+	pending := images
+
+	err := ExecuteWithProxyFailover(proxyList, func(proxy *models.ProxyItem) error {
+		useDockerProxy(proxy)
+
+		var failed []pullResult
+		for _, result := range pull(pending) {
+			errs[result.Image] = result.Err
+			if result.Err != nil {
+				failed = append(failed, result)
+			}
+		}
+
+		pending = pending[:0:0]
+		for _, result := range failed {
+			pending = append(pending, result.Image)
+		}
+
+		switch len(failed) {
+		case 0:
+			return nil
+		case 1:
+			return failed[0].Err
+		default:
+			return fmt.Errorf("%d 个镜像%s失败", len(failed), action)
+		}
+	}, action, dockerFailoverOptions())
+
+	if err != nil && config.Debug {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+	}
+
+	results := make([]pullResult, len(images))
+	for i, image := range images {
+		imageErr, tried := errs[image]
+		if !tried {
+			imageErr = err
+		}
+		results[i] = pullResult{Image: image, Err: imageErr}
+	}
+	return results
+}
+
+// dockerPullImages 处理 cnfast docker pull 命令
+// args: pull 之后的全部参数
+// proxyList: 按优先顺序排列的代理列表
+func dockerPullImages(args []string, proxyList []models.ProxyItem) {
+	// --platform 支持逗号分隔的多个平台，由 cnfast 逐个拉取
+	platformValue, args, hasPlatform := util.ExtractFlagValue(args, "--platform")
+	platformTag, args := util.ExtractBoolFlag(args, "--platform-tag")
+
+	images, pullFlags, concurrency, err := parsePullArgs(args)
+	if err == nil && hasPlatform && len(splitPlatforms(platformValue)) == 0 {
+		err = fmt.Errorf("--platform 参数不能为空")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		fmt.Fprintf(os.Stderr, "用法: cnfast docker pull [选项] <镜像>... [-f 镜像列表文件] [-j 并发数] [--platform 平台,...] [--platform-tag]\n")
+		os.Exit(1)
+	}
+
+	useImageLock()
+	results := pullWithFailover(images, proxyList, func(pending []string) []pullResult {
+		if hasPlatform {
+			return pullImagesForPlatforms(pending, splitPlatforms(platformValue), pullFlags, concurrency, platformTag)
+		}
+		return pullImages(pending, pullFlags, concurrency)
+	})
+	if printPullReport(results) > 0 {
+		os.Exit(1)
+	}
+}
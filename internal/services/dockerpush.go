@@ -0,0 +1,124 @@
+// Package services 包含 Docker 镜像推送逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+
+	"fmt"
+	"os"
+	"strings"
+)
+
+// dockerPushValueFlags docker push 中需要携带参数值的选项
+var dockerPushValueFlags = []string{"--platform"}
+
+// parsePushArgs 解析 docker push 参数
+// 返回: 镜像名、透传给 docker push 的选项
+func parsePushArgs(args []string) (string, []string) {
+	var image string
+	var pushFlags []string
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			if image == "" {
+				image = arg
+			}
+			continue
+		}
+
+		pushFlags = append(pushFlags, arg)
+		if isCommandSupported(arg, dockerPushValueFlags) && i+1 < len(args) {
+			pushFlags = append(pushFlags, args[i+1])
+			i++
+		}
+	}
+
+	return image, pushFlags
+}
+
+// dockerPushImage 处理 cnfast docker push 命令
+// 为本地镜像创建加速域名的临时标签，经代理推送后删除该标签；
+// 代理推送失败时切换到下一个支持推送的代理，没有代理支持推送时直接推送到原始仓库
+// args: push 之后的全部参数
+// proxyList: 按优先顺序排列的代理列表
+func dockerPushImage(args []string, proxyList []models.ProxyItem) {
+	image, pushFlags := parsePushArgs(args)
+	if image == "" {
+		fmt.Fprintln(os.Stderr, "错误: 未指定需要推送的镜像")
+		fmt.Fprintln(os.Stderr, "用法: cnfast docker push [选项] <镜像>")
+		os.Exit(1)
+	}
+
+	if ref, err := reference.Parse(image); err == nil && ref.Digest != "" {
+		fmt.Fprintf(os.Stderr, "错误: 不能推送摘要引用 %s，请指定标签\n", image)
+		os.Exit(1)
+	}
+
+	// 只有支持推送的代理才参与切换
+	var pushProxies []models.ProxyItem
+	for _, proxy := range proxyList {
+		if proxy.SupportPush {
+			pushProxies = append(pushProxies, proxy)
+		}
+	}
+
+	var err error
+	switch {
+	case replaceImageWithSpecificDomain(image) == image:
+		// 私有仓库等不需要加速的镜像
+		err = runDockerPush(image, image, pushFlags)
+	case isCommandSupported("-a", pushFlags) || isCommandSupported("--all-tags", pushFlags):
+		fmt.Println("提示: --all-tags 不支持经代理推送，将直接推送到原始仓库")
+		err = runDockerPush(image, image, pushFlags)
+	case len(pushProxies) == 0:
+		fmt.Println("提示: 当前加速服务均不支持推送，将直接推送到原始仓库")
+		err = runDockerPush(image, image, pushFlags)
+	default:
+		err = ExecuteWithProxyFailover(pushProxies, func(proxy *models.ProxyItem) error {
+			useDockerProxy(proxy)
+			if proxy == nil {
+				return runDockerPush(image, image, pushFlags)
+			}
+			return pushThroughProxy(image, replaceImageWithSpecificDomain(image), pushFlags)
+		}, "推送", dockerFailoverOptions())
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "命令执行失败: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// pushThroughProxy 创建加速域名的临时标签并经代理推送，完成后删除临时标签
+func pushThroughProxy(image, accelerated string, pushFlags []string) error {
+	fmt.Printf("镜像加速: %s -> %s\n", image, accelerated)
+
+	if err := tagImage(image, accelerated); err != nil {
+		return fmt.Errorf("创建临时标签失败: %w", err)
+	}
+	defer removeImageTag(accelerated, os.Stderr)
+
+	return runDockerPush(image, accelerated, pushFlags)
+}
+
+// runDockerPush 执行 docker push
+// original: 原始镜像名，用于查找凭据
+// target: 实际推送的镜像名
+func runDockerPush(original, target string, pushFlags []string) error {
+	args := append([]string{"push"}, pushFlags...)
+	args = append(args, target)
+
+	if config.Debug {
+		fmt.Printf("执行命令: docker %s\n", strings.Join(args, " "))
+	}
+
+	cmd, cleanup := newAcceleratedDockerCmd(original, target, args...)
+	defer cleanup()
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
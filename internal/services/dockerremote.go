@@ -0,0 +1,204 @@
+// Package services 包含远程镜像仓库的查询逻辑
+package services
+
+import (
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/registry"
+	"cnfast/internal/pkg/util"
+
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// remoteQueryTimeout 单次远程查询的超时时间
+const remoteQueryTimeout = 60 * time.Second
+
+// remotePlatform 远程镜像单个平台的信息
+type remotePlatform struct {
+	// Platform 平台，如 linux/amd64
+	Platform string
+
+	// Digest 平台清单摘要
+	Digest string
+
+	// Layers 层数
+	Layers int
+
+	// Size 各层压缩后的总大小（字节）
+	Size int64
+}
+
+// remoteTarget 返回经当前加速域名访问镜像使用的 registry 主机、仓库路径与标签（或摘要）
+// 镜像不需要加速或当前为直连时访问原始仓库
+func remoteTarget(image string) (string, string, string, error) {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	target := ref.Digest
+	if target == "" {
+		target = ref.Tag
+	}
+	if target == "" {
+		target = "latest"
+	}
+
+	accelerated := replaceImageWithSpecificDomain(image)
+	if accelerated == image {
+		return registry.APIHost(ref.Domain), ref.Path, target, nil
+	}
+
+	accelRef, err := reference.Parse(accelerated)
+	if err != nil {
+		return "", "", "", err
+	}
+	return accelRef.Domain, accelRef.Path, target, nil
+}
+
+// queryRemote 依次使用 docker 代理执行远程查询，失败时切换代理
+func queryRemote(proxyList []models.ProxyItem, query func() error) error {
+	return ExecuteWithProxyFailover(proxyList, func(proxy *models.ProxyItem) error {
+		useDockerProxy(proxy)
+		return query()
+	}, "查询", dockerFailoverOptions())
+}
+
+// dockerListTags 处理 cnfast docker tags 命令，列出远程仓库的全部标签
+// args: tags 之后的全部参数
+// proxyList: 按优先顺序排列的代理列表
+func dockerListTags(args []string, proxyList []models.ProxyItem) {
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "用法: cnfast docker tags <镜像>")
+		os.Exit(1)
+	}
+	image := args[0]
+
+	var tags []string
+	err := queryRemote(proxyList, func() error {
+		host, repo, _, err := remoteTarget(image)
+		if err != nil {
+			return abortFailover(err)
+		}
+
+		client := registry.NewClient(registryAuthFunc(image))
+		ctx, cancel := context.WithTimeout(context.Background(), remoteQueryTimeout)
+		defer cancel()
+
+		tags, err = client.ListTags(ctx, host, repo)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, tag := range tags {
+		fmt.Println(tag)
+	}
+	fmt.Fprintf(os.Stderr, "共 %d 个标签\n", len(tags))
+}
+
+// dockerInspectRemote 处理 cnfast docker inspect-remote 命令
+// 不拉取镜像，输出清单摘要、支持的平台、层数与压缩后大小
+// args: inspect-remote 之后的全部参数
+// proxyList: 按优先顺序排列的代理列表
+func dockerInspectRemote(args []string, proxyList []models.ProxyItem) {
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		fmt.Fprintln(os.Stderr, "用法: cnfast docker inspect-remote <镜像>")
+		os.Exit(1)
+	}
+	image := args[0]
+
+	var manifest *registry.Manifest
+	var platforms []remotePlatform
+	err := queryRemote(proxyList, func() error {
+		host, repo, target, err := remoteTarget(image)
+		if err != nil {
+			return abortFailover(err)
+		}
+
+		client := registry.NewClient(registryAuthFunc(image))
+		ctx, cancel := context.WithTimeout(context.Background(), remoteQueryTimeout)
+		defer cancel()
+
+		manifest, platforms, err = inspectRemoteManifest(ctx, client, host, repo, target)
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("镜像: %s\n", image)
+	fmt.Printf("摘要: %s\n", manifest.Digest)
+	fmt.Printf("类型: %s\n", manifest.MediaType)
+	fmt.Println()
+	fmt.Printf("%-20s %-20s %-6s %s\n", "平台", "摘要", "层数", "压缩大小")
+	fmt.Println(strings.Repeat("-", 64))
+	for _, platform := range platforms {
+		fmt.Printf("%-20s %-20s %-6d %s\n", platform.Platform, shortDigest(platform.Digest), platform.Layers, util.FormatSize(platform.Size))
+	}
+}
+
+// inspectRemoteManifest 获取清单以及各平台的层信息
+// 清单列表中的证明清单（平台为 unknown/unknown）不计入平台列表
+func inspectRemoteManifest(ctx context.Context, client *registry.Client, host, repo, target string) (*registry.Manifest, []remotePlatform, error) {
+	manifest, err := client.GetManifest(ctx, host, repo, target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !manifest.IsIndex() {
+		platform, err := singleManifestPlatform(ctx, client, host, repo, manifest)
+		if err != nil {
+			return nil, nil, err
+		}
+		return manifest, []remotePlatform{platform}, nil
+	}
+
+	var platforms []remotePlatform
+	for _, desc := range manifest.Manifests {
+		if desc.Platform == nil || desc.Platform.OS == "unknown" {
+			continue
+		}
+
+		child, err := client.GetManifest(ctx, host, repo, desc.Digest)
+		if err != nil {
+			return nil, nil, fmt.Errorf("获取平台 %s 的清单失败: %w", desc.Platform.String(), err)
+		}
+		platforms = append(platforms, remotePlatform{
+			Platform: desc.Platform.String(),
+			Digest:   desc.Digest,
+			Layers:   len(child.Layers),
+			Size:     child.TotalSize(),
+		})
+	}
+	return manifest, platforms, nil
+}
+
+// singleManifestPlatform 读取单平台清单的镜像配置以确定平台
+func singleManifestPlatform(ctx context.Context, client *registry.Client, host, repo string, manifest *registry.Manifest) (remotePlatform, error) {
+	platform := remotePlatform{
+		Platform: "未知",
+		Digest:   manifest.Digest,
+		Layers:   len(manifest.Layers),
+		Size:     manifest.TotalSize(),
+	}
+	if manifest.Config == nil {
+		return platform, nil
+	}
+
+	var cfg registry.Platform
+	if err := client.GetBlobJSON(ctx, host, repo, manifest.Config.Digest, &cfg); err != nil {
+		return platform, err
+	}
+	if cfg.OS != "" {
+		platform.Platform = cfg.String()
+	}
+	return platform, nil
+}
@@ -0,0 +1,225 @@
+// Package services 包含 docker run/create 的镜像预拉取逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+
+	"fmt"
+	"os"
+	"strings"
+)
+
+// dockerRunBoolFlags docker run/create 中不带参数值的选项
+// 其余选项（如 -e、-v、--name）都需要携带参数值
+var dockerRunBoolFlags = []string{
+	"-d", "--detach",
+	"-i", "--interactive",
+	"-t", "--tty",
+	"-P", "--publish-all",
+	"-q", "--quiet",
+	"--rm",
+	"--init",
+	"--privileged",
+	"--read-only",
+	"--no-healthcheck",
+	"--oom-kill-disable",
+	"--sig-proxy",
+	"--disable-content-trust",
+	"--use-api-socket",
+	"--help",
+}
+
+// dockerRunBoolShortFlags 可以组合使用的单字母布尔选项，如 -it、-dit
+const dockerRunBoolShortFlags = "diPqt"
+
+// dockerRunOptions 从 docker run/create 参数中解析出的预拉取信息
+type dockerRunOptions struct {
+	// Image 镜像参数，未找到时为空
+	Image string
+
+	// ImageIndex 镜像参数在 run/create 之后参数中的位置，未找到时为 -1
+	ImageIndex int
+
+	// Platform --platform 指定的平台
+	Platform string
+
+	// Pull --pull 指定的拉取策略: missing、always 或 never
+	Pull string
+}
+
+// IsDockerRunCommand 判断是否为需要预拉取镜像的 docker run/create 命令
+// args: docker 之后的全部参数
+func IsDockerRunCommand(args []string) bool {
+	return len(args) >= 1 && (args[0] == "run" || args[0] == "create")
+}
+
+// DockerRun 处理 cnfast docker run/create 命令
+// 本地缺少镜像时先经加速域名拉取并重新打标签，然后原样执行 docker 命令
+// 预拉取失败不会中断命令，docker 会自行拉取镜像
+// args: docker 之后的全部参数
+// loadProxies: 获取按优先顺序排列的代理列表，只在需要预拉取时调用
+func DockerRun(args []string, loadProxies func() ([]models.ProxyItem, error)) {
+	opts := parseDockerRunArgs(args[1:])
+	if opts.Image != "" {
+		if runImage := prepareRunImage(opts, loadProxies); runImage != opts.Image {
+			fmt.Fprintf(os.Stderr, "使用本地镜像 %s 代替 %s\n", runImage, opts.Image)
+			args = replaceRunImage(args, opts, runImage)
+		}
+	}
+
+	if config.Debug {
+		fmt.Fprintf(os.Stderr, "执行命令: docker %s\n", strings.Join(args, " "))
+	}
+	execCommand("docker", args)
+}
+
+// parseDockerRunArgs 按 docker 的选项规则找到镜像参数
+// args: run/create 之后的全部参数
+func parseDockerRunArgs(args []string) dockerRunOptions {
+	opts := dockerRunOptions{Pull: "missing", ImageIndex: -1}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// 第一个非选项参数即镜像，之后为容器命令
+		if arg == "--" {
+			if i+1 < len(args) {
+				opts.Image, opts.ImageIndex = args[i+1], i+1
+			}
+			return opts
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			opts.Image, opts.ImageIndex = arg, i
+			return opts
+		}
+
+		name, value, hasValue := arg, "", false
+		if strings.HasPrefix(arg, "--") {
+			if eq := strings.Index(arg, "="); eq >= 0 {
+				name, value, hasValue = arg[:eq], arg[eq+1:], true
+			}
+		} else if len(arg) > 2 {
+			// 组合的短选项: 布尔选项之后的第一个字母若需要参数值，则其余部分或下一个参数为值
+			j := 1
+			for j < len(arg) && strings.ContainsRune(dockerRunBoolShortFlags, rune(arg[j])) {
+				j++
+			}
+			if j == len(arg) {
+				continue
+			}
+			name = "-" + string(arg[j])
+			if j+1 < len(arg) {
+				value, hasValue = strings.TrimPrefix(arg[j+1:], "="), true
+			}
+		}
+
+		if isCommandSupported(name, dockerRunBoolFlags) {
+			continue
+		}
+		if !hasValue {
+			if i+1 >= len(args) {
+				return opts
+			}
+			i++
+			value = args[i]
+		}
+
+		switch name {
+		case "--platform":
+			opts.Platform = value
+		case "--pull":
+			opts.Pull = value
+		}
+	}
+	return opts
+}
+
+// localImageAvailable 判断本地是否已有镜像（指定平台时还需平台一致）
+func localImageAvailable(image, platform string) bool {
+	info, err := inspectImage(image)
+	if err != nil {
+		return false
+	}
+	return platform == "" || platformMatches(platform, info.Os, info.Architecture, info.Variant)
+}
+
+// prepareRunImage 按拉取策略预拉取镜像，返回 docker 命令实际应使用的镜像名
+// 经加速域名拉取的摘要引用在本地只有 localImageName 的标签（如 nginx:sha256-<hex>），
+// 原样执行时 docker 按摘要找不到本地镜像又会直接拉取，因此改用该标签
+func prepareRunImage(opts dockerRunOptions, loadProxies func() ([]models.ProxyItem, error)) string {
+	local := localImageName(opts.Image)
+	available := localImageAvailable(opts.Image, opts.Platform)
+
+	// 仅有摘要时本地标签与摘要一一对应，可以直接复用；同时带标签时标签可能已指向其他内容
+	ref, err := reference.Parse(opts.Image)
+	digestOnly := err == nil && ref.Digest != "" && ref.Tag == ""
+	if !available && digestOnly && opts.Pull != "always" && localImageAvailable(local, opts.Platform) {
+		return local
+	}
+
+	if opts.Pull == "never" || (opts.Pull != "always" && available) {
+		return opts.Image
+	}
+	if !prefetchRunImage(opts, loadProxies) || local == opts.Image {
+		return opts.Image
+	}
+	if !localImageAvailable(local, opts.Platform) {
+		return opts.Image
+	}
+	return local
+}
+
+// replaceRunImage 将 docker 参数中的镜像替换为本地标签
+// 该标签在原始仓库中不存在，--pull always 时追加 --pull=missing，避免 docker 再次按标签拉取
+// args: docker 之后的全部参数
+func replaceRunImage(args []string, opts dockerRunOptions, image string) []string {
+	index := opts.ImageIndex + 1
+	result := append([]string(nil), args[:index]...)
+	if opts.Pull == "always" {
+		// -- 之后不再解析选项，需要插在 -- 之前
+		at := len(result)
+		if at > 0 && result[at-1] == "--" {
+			at--
+		}
+		result = append(result[:at], append([]string{"--pull=missing"}, result[at:]...)...)
+	}
+	result = append(result, image)
+	return append(result, args[index+1:]...)
+}
+
+// prefetchRunImage 依次使用代理预拉取镜像，输出写入标准错误以免混入容器输出
+// 不提示选择代理，也不询问直连，以免读取容器需要的标准输入
+// 返回: 是否预拉取成功
+func prefetchRunImage(opts dockerRunOptions, loadProxies func() ([]models.ProxyItem, error)) bool {
+	proxyList, err := loadProxies()
+	if err != nil || len(proxyList) == 0 {
+		fmt.Fprintf(os.Stderr, "警告: 获取 Docker 代理服务失败，由 docker 直接拉取镜像: %v\n", err)
+		return false
+	}
+
+	var pullFlags []string
+	if opts.Platform != "" {
+		pullFlags = []string{"--platform", opts.Platform}
+	}
+
+	// 与 pull 一致按锁文件校验，但不在标准输出中提示
+	activeImageLock, err = loadImageLockFile(defaultLockFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v，跳过预拉取\n", err)
+		return false
+	}
+
+	fmt.Fprintf(os.Stderr, "预拉取镜像 %s\n", opts.Image)
+	for i := range proxyList {
+		useDockerProxy(&proxyList[i])
+		err = pullImage(opts.Image, pullFlags, os.Stderr)
+		if err == nil {
+			return true
+		}
+		fmt.Fprintf(os.Stderr, "代理 %s 预拉取失败: %v\n", proxyList[i].ProxyUrl, err)
+	}
+	fmt.Fprintln(os.Stderr, "警告: 预拉取失败，由 docker 直接拉取镜像")
+	return false
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDockerRunArgs(t *testing.T) {
+	tests := []struct {
+		args string
+		want dockerRunOptions
+	}{
+		{"nginx", dockerRunOptions{Image: "nginx", ImageIndex: 0, Pull: "missing"}},
+		{"--rm -it nginx sh -c ls", dockerRunOptions{Image: "nginx", ImageIndex: 2, Pull: "missing"}},
+		{"-d --name web -p 80:80 nginx:1.25", dockerRunOptions{Image: "nginx:1.25", ImageIndex: 5, Pull: "missing"}},
+		{"-e A=1 -v /a:/b -w /work ghcr.io/org/tool:1.0", dockerRunOptions{Image: "ghcr.io/org/tool:1.0", ImageIndex: 6, Pull: "missing"}},
+		{"--name=web --env=A=1 nginx", dockerRunOptions{Image: "nginx", ImageIndex: 2, Pull: "missing"}},
+		{"-dit nginx", dockerRunOptions{Image: "nginx", ImageIndex: 1, Pull: "missing"}},
+		{"-itv /a:/b nginx", dockerRunOptions{Image: "nginx", ImageIndex: 2, Pull: "missing"}},
+		{"-itv=/a:/b nginx", dockerRunOptions{Image: "nginx", ImageIndex: 1, Pull: "missing"}},
+		{"-it -v /a:/b nginx", dockerRunOptions{Image: "nginx", ImageIndex: 3, Pull: "missing"}},
+		{"--platform linux/arm64 alpine", dockerRunOptions{Image: "alpine", ImageIndex: 2, Platform: "linux/arm64", Pull: "missing"}},
+		{"--platform=linux/amd64 --pull=always alpine", dockerRunOptions{Image: "alpine", ImageIndex: 2, Platform: "linux/amd64", Pull: "always"}},
+		{"--pull never alpine", dockerRunOptions{Image: "alpine", ImageIndex: 2, Pull: "never"}},
+		{"--rm -- alpine echo hi", dockerRunOptions{Image: "alpine", ImageIndex: 2, Pull: "missing"}},
+		{"--rm --", dockerRunOptions{ImageIndex: -1, Pull: "missing"}},
+		{"--rm --name", dockerRunOptions{ImageIndex: -1, Pull: "missing"}},
+		{"", dockerRunOptions{ImageIndex: -1, Pull: "missing"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseDockerRunArgs(strings.Fields(tt.args)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseDockerRunArgs(%q) = %+v, 期望 %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceRunImage(t *testing.T) {
+	const local = "nginx:sha256-abc"
+
+	tests := []struct {
+		args string
+		want string
+	}{
+		{"run --rm -it nginx@sha256:abc sh", "run --rm -it nginx:sha256-abc sh"},
+		{"create -e A=1 nginx@sha256:abc", "create -e A=1 nginx:sha256-abc"},
+		{"run --pull always nginx@sha256:abc", "run --pull always --pull=missing nginx:sha256-abc"},
+		{"run --pull=always -- nginx@sha256:abc echo", "run --pull=always --pull=missing -- nginx:sha256-abc echo"},
+	}
+
+	for _, tt := range tests {
+		args := strings.Fields(tt.args)
+		opts := parseDockerRunArgs(args[1:])
+		if got := strings.Join(replaceRunImage(args, opts, local), " "); got != tt.want {
+			t.Errorf("replaceRunImage(%q) = %q, 期望 %q", tt.args, got, tt.want)
+		}
+	}
+}
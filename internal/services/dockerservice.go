@@ -4,9 +4,14 @@ package services
 import (
 	"cnfast/config"
 	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/util"
 
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"sort"
@@ -16,24 +21,37 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// defaultAccelDomain 内置的基础加速域名
+const defaultAccelDomain = "docker.521456.xyz"
+
 // Docker 镜像加速配置
 var (
 	// baseAccelDomain 基础加速域名
-	baseAccelDomain = "docker.521456.xyz"
+	baseAccelDomain = defaultAccelDomain
+
+	// defaultRegistryTemplates 内置的镜像源到加速域名的映射模板
+	// {proxy} 会被替换为基础加速域名，{host} 为镜像源域名，
+	// 包含 {path} 的模板表示完整的仓库地址，否则仓库路径追加在其后
+	defaultRegistryTemplates = map[string]string{
+		"quay.io":                       "quay.{proxy}",
+		"gcr.io":                        "gcr.{proxy}",
+		"k8s.gcr.io":                    "k8s-gcr.{proxy}",
+		"registry.k8s.io":               "k8s.{proxy}",
+		"ghcr.io":                       "ghcr.{proxy}",
+		"docker.cloudsmith.io":          "cloudsmith.{proxy}",
+		"nvcr.io":                       "nvcr.{proxy}",
+		"mcr.microsoft.com":             "mcr.{proxy}",
+		"public.ecr.aws":                "ecr.{proxy}",
+		"registry.gitlab.com":           "gitlab.{proxy}",
+		"docker.elastic.co":             "elastic.{proxy}",
+		"container-registry.oracle.com": "oracle.{proxy}",
+		"registry-1.docker.io":          "{proxy}",
+		"docker.io":                     "{proxy}", // 默认 Docker 官方仓库
+	}
 
 	// registryToAccelDomain 镜像源到加速域名的映射
 	// 将各种 Docker registry 映射到对应的加速域名
-	registryToAccelDomain = map[string]string{
-		"quay.io":              "quay." + baseAccelDomain,
-		"gcr.io":               "gcr." + baseAccelDomain,
-		"k8s.gcr.io":           "k8s-gcr." + baseAccelDomain,
-		"registry.k8s.io":      "k8s." + baseAccelDomain,
-		"ghcr.io":              "ghcr." + baseAccelDomain,
-		"docker.cloudsmith.io": "cloudsmith." + baseAccelDomain,
-		"nvcr.io":              "nvcr." + baseAccelDomain,
-		"registry-1.docker.io": baseAccelDomain,
-		"docker.io":            baseAccelDomain, // 默认 Docker 官方仓库
-	}
+	registryToAccelDomain = buildRegistryMapping(baseAccelDomain, nil, "")
 
 	// accelDomains 需要加速的域名列表
 	accelDomains = getAccelDomains()
@@ -48,33 +66,89 @@ func getAccelDomains() []string {
 	return domains
 }
 
+// buildRegistryMapping 生成镜像源到加速域名的映射
+// 依次合并内置模板、代理服务下发的模板和用户配置中的模板，后者覆盖前者；
+// 指定了镜像地址模板时，内置映射中的镜像源统一使用该模板；
+// 模板值为空表示该镜像源不加速
+// domain: 基础加速域名
+// proxyTemplates: 代理服务下发的映射模板
+// imageTemplate: 代理服务下发的镜像地址模板
+func buildRegistryMapping(domain string, proxyTemplates map[string]string, imageTemplate string) map[string]string {
+	if config.User.ImageTemplate != "" {
+		imageTemplate = config.User.ImageTemplate
+	}
+
+	templates := make(map[string]string, len(defaultRegistryTemplates))
+	for registry, template := range defaultRegistryTemplates {
+		if imageTemplate != "" {
+			template = imageTemplate
+		}
+		templates[registry] = template
+	}
+	for _, source := range []map[string]string{proxyTemplates, config.User.RegistryMapping} {
+		for registry, template := range source {
+			templates[registry] = template
+		}
+	}
+
+	mapping := make(map[string]string, len(templates))
+	for registry, template := range templates {
+		template = strings.TrimSpace(template)
+		if template == "" {
+			continue
+		}
+		// 保留 {path}，在替换具体镜像时渲染
+		rendered := util.RenderTemplate(template, map[string]string{
+			"proxy": strings.TrimRight(domain, "/"),
+			"host":  registry,
+		})
+		mapping[registry] = strings.TrimRight(rendered, "/")
+	}
+	return mapping
+}
+
 // SetBaseAccelDomain 设置基础加速域名并重新生成映射
 // domain: 新的基础加速域名
 func SetBaseAccelDomain(domain string) {
+	SetRegistryMapping(domain, nil, "")
+}
+
+// SetRegistryMapping 设置基础加速域名及代理服务下发的模板，并重新生成映射
+// domain: 新的基础加速域名，为空表示不使用加速
+// proxyTemplates: 代理服务下发的映射模板，可以为 nil
+// imageTemplate: 代理服务下发的镜像地址模板，可以为空
+func SetRegistryMapping(domain string, proxyTemplates map[string]string, imageTemplate string) {
 	if config.Debug {
 		fmt.Printf("设置代理域名: %s\n", domain)
 	}
 	baseAccelDomain = domain
 
-	// 重新生成完整的加速域名映射
-	registryToAccelDomain = map[string]string{
-		"quay.io":              "quay." + baseAccelDomain,
-		"gcr.io":               "gcr." + baseAccelDomain,
-		"k8s.gcr.io":           "k8s-gcr." + baseAccelDomain,
-		"registry.k8s.io":      "k8s." + baseAccelDomain,
-		"ghcr.io":              "ghcr." + baseAccelDomain,
-		"docker.cloudsmith.io": "cloudsmith." + baseAccelDomain,
-		"nvcr.io":              "nvcr." + baseAccelDomain,
-		"registry-1.docker.io": baseAccelDomain,
-		"docker.io":            baseAccelDomain,
+	// 基础域名为空表示不使用加速，所有镜像保持原样
+	if baseAccelDomain == "" {
+		registryToAccelDomain = map[string]string{}
+		accelDomains = getAccelDomains()
+		return
 	}
 
+	// 重新生成完整的加速域名映射
+	registryToAccelDomain = buildRegistryMapping(baseAccelDomain, proxyTemplates, imageTemplate)
+
 	// 更新加速域名列表
 	accelDomains = getAccelDomains()
 }
 
+// useDockerProxy 切换当前使用的 docker 代理
+// proxy 为 nil 时不使用加速，直连原始仓库
+func useDockerProxy(proxy *models.ProxyItem) {
+	if proxy == nil {
+		SetBaseAccelDomain("")
+		return
+	}
+	SetRegistryMapping(proxy.ProxyUrl, proxy.RegistryMapping, proxy.ImageTemplate)
+}
+
 // DockerProxy 执行 Docker 命令并应用镜像加速
-// proxyList: 代理服务列表
+// proxyList: 代理服务列表，按优先顺序排列，失败时依次切换
 // dockerFlag: 是否为 docker 命令（true）还是 docker-compose 命令（false）
 func DockerProxy(proxyList []models.ProxyItem, dockerFlag bool) {
 	// 如果不是 docker 命令，则处理 docker-compose
@@ -90,18 +164,15 @@ func DockerProxy(proxyList []models.ProxyItem, dockerFlag bool) {
 		os.Exit(1)
 	}
 
-	// 代理服务在上游已选择，这里直接使用第一个
+	// 代理服务在上游已排序，默认使用第一个，失败时由切换框架依次尝试
 	if len(proxyList) == 0 {
 		fmt.Fprintf(os.Stderr, "错误: 未找到可用的代理服务\n")
 		os.Exit(1)
 	}
-
-	bestProxy := &proxyList[0]
-	fmt.Printf("使用代理: %s (评分: %d)\n", bestProxy.ProxyUrl, bestProxy.Score)
-	SetBaseAccelDomain(bestProxy.ProxyUrl)
+	useDockerProxy(&proxyList[0])
 
 	// 支持的命令列表
-	supportedCommands := []string{"pull", "push", "build"}
+	supportedCommands := []string{"pull", "push", "build", "bundle", "lock", "tags", "inspect-remote", "sync", "buildx"}
 	command := os.Args[2]
 
 	// 检查命令是否支持
@@ -111,26 +182,37 @@ func DockerProxy(proxyList []models.ProxyItem, dockerFlag bool) {
 		os.Exit(1)
 	}
 
-	// 构建新的参数列表
-	newArgs := []string{command}
-	var originalImage string    // 原始镜像名
-	var acceleratedImage string // 加速后的镜像名
-	var needRetagging bool      // 是否需要重新打标签
-
-	for idx, arg := range os.Args[3:] {
-		// 如果是镜像参数，进行加速替换
-		if idx == 0 && (command == "pull" || command == "push") {
-			originalImage = arg
-			acceleratedImage = replaceImageWithSpecificDomain(arg)
-			if acceleratedImage != originalImage {
-				fmt.Printf("镜像加速: %s -> %s\n", originalImage, acceleratedImage)
-				needRetagging = (command == "pull") // 只有 pull 命令需要重新打标签
-			}
-			arg = acceleratedImage
-		}
-		newArgs = append(newArgs, arg)
+	switch command {
+	case "pull":
+		// pull 命令支持多个镜像与镜像列表文件，单独处理
+		dockerPullImages(os.Args[3:], proxyList)
+		return
+	case "push":
+		dockerPushImage(os.Args[3:], proxyList)
+		return
+	case "bundle":
+		DockerBundle(os.Args[3:], proxyList)
+		return
+	case "lock":
+		dockerLockImages(os.Args[3:], proxyList)
+		return
+	case "tags":
+		dockerListTags(os.Args[3:], proxyList)
+		return
+	case "inspect-remote":
+		dockerInspectRemote(os.Args[3:], proxyList)
+		return
+	case "sync":
+		dockerSyncImages(os.Args[3:], proxyList)
+		return
+	case "buildx":
+		dockerBuildx(os.Args[3:])
+		return
 	}
 
+	// 其余命令保留原始参数
+	newArgs := append([]string{command}, os.Args[3:]...)
+
 	if config.Debug {
 		fmt.Printf("执行命令: docker %s\n", strings.Join(newArgs, " "))
 	}
@@ -146,26 +228,18 @@ func DockerProxy(proxyList []models.ProxyItem, dockerFlag bool) {
 		fmt.Fprintf(os.Stderr, "命令执行失败: %v\n", err)
 		os.Exit(1)
 	}
-
-	// 如果是 pull 命令且使用了加速，需要重新打标签
-	if needRetagging {
-		retagImage(acceleratedImage, originalImage)
-	}
 }
 
-// getBestProxy 获取评分最高的代理服务
-func getBestProxy(proxyList []models.ProxyItem) *models.ProxyItem {
-	if len(proxyList) == 0 {
-		return nil
-	}
+// IsOfflineDockerCommand 判断是否为无需代理服务即可执行的 docker 命令
+// args: docker 之后的全部参数
+func IsOfflineDockerCommand(args []string) bool {
+	return len(args) >= 2 && args[0] == "bundle" && args[1] == "load"
+}
 
-	best := &proxyList[0]
-	for i := 1; i < len(proxyList); i++ {
-		if proxyList[i].Score > best.Score {
-			best = &proxyList[i]
-		}
-	}
-	return best
+// DockerOfflineCommand 执行无需代理服务的 docker 命令
+// 例如在无外网的服务器上导入离线镜像包
+func DockerOfflineCommand() {
+	DockerBundle(os.Args[3:], nil)
 }
 
 // isCommandSupported 检查命令是否在支持列表中
@@ -181,54 +255,154 @@ func isCommandSupported(command string, supportedCommands []string) bool {
 // replaceImageWithSpecificDomain 根据映射表替换镜像域名
 // raw: 原始镜像名称
 // 返回: 加速后的镜像名称
+// 不在映射表中的 registry（如私有仓库）以及无法解析的名称保持不变
 func replaceImageWithSpecificDomain(raw string) string {
-	// 检查镜像名称是否包含域名
-	splits := strings.SplitN(raw, "/", 2)
-	if len(splits) == 1 {
-		// 没有域名，使用默认加速域名
-		return baseAccelDomain + "/" + raw
+	ref, err := reference.Parse(raw)
+	if err != nil {
+		if config.Debug {
+			fmt.Printf("镜像名称解析失败，保持不变: %v\n", err)
+		}
+		return raw
+	}
+
+	accelDomain, exists := registryToAccelDomain[ref.Domain]
+	if !exists {
+		return raw
+	}
+
+	// 同时带标签和摘要时以摘要为准，标签在重新打标签时恢复
+	if ref.Digest != "" {
+		ref.Tag = ""
+	}
+
+	// 完整仓库地址模板，如 {proxy}/{host}/{path}
+	if util.HasPlaceholder(accelDomain, "path") {
+		return util.RenderTemplate(accelDomain, map[string]string{"path": ref.Path}) + ref.Suffix()
 	}
+	return ref.WithDomain(accelDomain)
+}
 
-	// 检查第一部分是否是已知的 registry 域名
-	if accelDomain, exists := registryToAccelDomain[splits[0]]; exists {
-		return accelDomain + "/" + splits[1]
+// localImageName 返回拉取完成后本地使用的镜像名称
+// 摘要无法作为 docker tag 的目标，因此：
+//   - 同时带标签和摘要时，使用标签
+//   - 仅有摘要时，使用 sha256-<hex> 形式的标签
+func localImageName(original string) string {
+	ref, err := reference.Parse(original)
+	if err != nil || ref.Digest == "" {
+		return original
 	}
 
-	// 如果第一部分不是已知的 registry 域名，但有斜杠分隔，
-	// 说明这是 Docker Hub 上的镜像（如 homeassistant/home-assistant:stable）
-	// 应该使用默认加速域名
-	return baseAccelDomain + "/" + raw
+	tag := ref.Tag
+	if tag == "" {
+		tag = strings.Replace(ref.Digest, ":", "-", 1)
+	}
+	return ref.FamiliarName() + ":" + tag
 }
 
 // retagImage 将加速域名的镜像重新打标签为原始名称
 // acceleratedImage: 带加速域名的镜像名
 // originalImage: 原始镜像名
-func retagImage(acceleratedImage, originalImage string) {
+// out: 警告信息的写入目标，并发拉取时为各任务的输出缓冲
+func retagImage(acceleratedImage, originalImage string, out io.Writer) {
 	// 1. 使用原始名称重新打标签
-	tagCmd := exec.Command("docker", "tag", acceleratedImage, originalImage)
-	tagCmd.Stdout = os.Stdout
-	tagCmd.Stderr = os.Stderr
-
-	if err := tagCmd.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "警告: 重新打标签失败: %v\n", err)
-		fmt.Fprintf(os.Stderr, "镜像仍然可用，但标签为: %s\n", acceleratedImage)
+	originalImage = localImageName(originalImage)
+	if err := tagImage(acceleratedImage, originalImage); err != nil {
+		fmt.Fprintf(out, "警告: 重新打标签失败: %v\n", err)
+		fmt.Fprintf(out, "镜像仍然可用，但标签为: %s\n", acceleratedImage)
 		return
 	}
 
 	// 2. 删除加速域名的标签（清理临时标签）
-	rmiCmd := exec.Command("docker", "rmi", acceleratedImage)
-	// 不显示删除输出，保持界面简洁
-	if config.Debug {
-		rmiCmd.Stdout = os.Stdout
-		rmiCmd.Stderr = os.Stderr
+	removeImageTag(acceleratedImage, out)
+}
+
+// tagImage 为镜像添加标签，优先使用 Engine API
+// docker tag 的输出不直接显示，失败时包含在返回的错误中
+func tagImage(source, target string) error {
+	if client := dockerEngine(); client != nil {
+		return client.TagImage(context.Background(), source, target)
 	}
 
-	if err := rmiCmd.Run(); err != nil {
-		if config.Debug {
-			fmt.Fprintf(os.Stderr, "警告: 删除旧标签失败: %v\n", err)
+	output, err := exec.Command("docker", "tag", source, target).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// removeImageTag 删除镜像标签（镜像仍被其他标签引用时只会移除该标签）
+// 删除失败不影响镜像使用，仅在调试模式下向 out 输出警告
+func removeImageTag(image string, out io.Writer) {
+	if err := untagImage(image); err != nil && config.Debug {
+		fmt.Fprintf(out, "警告: 删除旧标签失败: %v\n", err)
+	}
+}
+
+// untagImage 删除镜像标签，优先使用 Engine API
+// docker rmi 的输出不直接显示，失败时包含在返回的错误中
+func untagImage(image string) error {
+	if client := dockerEngine(); client != nil {
+		return client.RemoveImage(context.Background(), image)
+	}
+
+	output, err := exec.Command("docker", "rmi", image).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%v %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// imageInfo docker image inspect 返回的镜像信息（仅包含需要的字段）
+type imageInfo struct {
+	// ID 镜像 ID（配置文件的 sha256 摘要）
+	ID string `json:"Id"`
+
+	// RepoTags 镜像的全部标签
+	RepoTags []string `json:"RepoTags"`
+
+	// RepoDigests 镜像的仓库摘要，形如 name@sha256:...
+	RepoDigests []string `json:"RepoDigests"`
+
+	// Os 镜像的操作系统
+	Os string `json:"Os"`
+
+	// Architecture 镜像的 CPU 架构
+	Architecture string `json:"Architecture"`
+
+	// Variant 镜像的架构变体（如 arm 的 v7）
+	Variant string `json:"Variant"`
+}
+
+// inspectImage 查询本地镜像信息
+// image: 镜像名称或 ID
+func inspectImage(image string) (*imageInfo, error) {
+	if client := dockerEngine(); client != nil {
+		output, err := client.InspectImage(context.Background(), image)
+		if err != nil {
+			return nil, fmt.Errorf("查询镜像 %s 失败: %w", image, err)
 		}
-		// 忽略删除失败，因为不影响镜像使用
+
+		info := &imageInfo{}
+		if err := json.Unmarshal(output, info); err != nil {
+			return nil, fmt.Errorf("解析镜像 %s 信息失败: %w", image, err)
+		}
+		return info, nil
+	}
+
+	output, err := exec.Command("docker", "image", "inspect", image).Output()
+	if err != nil {
+		return nil, fmt.Errorf("查询镜像 %s 失败: %w", image, err)
+	}
+
+	var infos []imageInfo
+	if err := json.Unmarshal(output, &infos); err != nil {
+		return nil, fmt.Errorf("解析镜像 %s 信息失败: %w", image, err)
+	}
+	if len(infos) == 0 {
+		return nil, fmt.Errorf("未找到镜像 %s", image)
 	}
+
+	return &infos[0], nil
 }
 
 // runComposeConfig 尝试兼容 docker compose 与 docker-compose 两种命令
@@ -254,24 +428,18 @@ func runComposeConfig(composeFile string) ([]byte, error) {
 	return output, fmt.Errorf("docker compose 失败: %v; docker-compose 失败: %v", err, err2)
 }
 
-// DockerComposeProxy 处理 docker-compose 命令的代理
-// proxyList: 代理服务列表
-func DockerComposeProxy(proxyList []models.ProxyItem) {
-	if len(proxyList) == 0 {
-		fmt.Fprintln(os.Stderr, "错误: 未找到可用的代理服务")
-		os.Exit(1)
-	}
-
-	best := getBestProxy(proxyList)
-	if best == nil {
-		fmt.Fprintln(os.Stderr, "错误: 未找到可用的代理服务")
-		os.Exit(1)
-	}
+// composeImage compose 配置中的镜像及使用该镜像的 service
+type composeImage struct {
+	// Image 镜像名
+	Image string
 
-	fmt.Printf("使用代理: %s (评分: %d)\n", best.ProxyUrl, best.Score)
-	SetBaseAccelDomain(best.ProxyUrl)
+	// Services 使用该镜像的 service 名称
+	Services []string
+}
 
-	// 只考虑单 compose 文件，在当前目录按常见命名查找
+// findComposeFile 在当前目录按常见命名查找 compose 文件，未找到时返回空字符串
+// 只考虑单 compose 文件
+func findComposeFile() string {
 	composeCandidates := []string{
 		"docker-compose.yml",
 		"docker-compose.yaml",
@@ -279,29 +447,21 @@ func DockerComposeProxy(proxyList []models.ProxyItem) {
 		"compose.yaml",
 	}
 
-	var composeFile string
 	for _, f := range composeCandidates {
 		if _, err := os.Stat(f); err == nil {
-			composeFile = f
-			break
+			return f
 		}
 	}
+	return ""
+}
 
-	if composeFile == "" {
-		fmt.Fprintln(os.Stderr, "错误: 当前目录未找到 docker compose 配置文件 (docker-compose.yml|docker-compose.yaml|compose.yml|compose.yaml)")
-		os.Exit(1)
-	}
-
-	if config.Debug {
-		fmt.Printf("使用 compose 文件: %s\n", composeFile)
-	}
-
+// loadComposeImages 解析 compose 文件中引用的镜像
+// 返回按镜像名排序、去重后的镜像列表
+func loadComposeImages(composeFile string) ([]*composeImage, error) {
 	// 使用 docker compose/docker-compose CLI 解析配置为 YAML
 	output, err := runComposeConfig(composeFile)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "错误: 解析 docker compose 配置失败: %v\n", err)
-		fmt.Fprintf(os.Stderr, "命令输出:\n%s\n", string(output))
-		os.Exit(1)
+		return nil, fmt.Errorf("解析 docker compose 配置失败: %v\n命令输出:\n%s", err, string(output))
 	}
 
 	// 解析 YAML，提取 services -> image 映射
@@ -313,17 +473,11 @@ func DockerComposeProxy(proxyList []models.ProxyItem) {
 
 	var cfg composeConfig
 	if err := yaml.Unmarshal(output, &cfg); err != nil {
-		fmt.Fprintf(os.Stderr, "错误: 解析 docker compose YAML 失败: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("解析 docker compose YAML 失败: %w", err)
 	}
 
 	// 构建去重后的镜像列表，同时记录使用该镜像的 service 名称
-	type imageItem struct {
-		Image    string
-		Services []string
-	}
-
-	imageMap := make(map[string]*imageItem)
+	imageMap := make(map[string]*composeImage)
 
 	for svcName, svc := range cfg.Services {
 		if svc.Image == "" {
@@ -332,20 +486,16 @@ func DockerComposeProxy(proxyList []models.ProxyItem) {
 		if item, ok := imageMap[svc.Image]; ok {
 			item.Services = append(item.Services, svcName)
 		} else {
-			imageMap[svc.Image] = &imageItem{
+			imageMap[svc.Image] = &composeImage{
 				Image:    svc.Image,
 				Services: []string{svcName},
 			}
 		}
 	}
 
-	if len(imageMap) == 0 {
-		fmt.Println("未在 compose 配置中找到任何需要拉取的镜像")
-		return
-	}
-
-	images := make([]*imageItem, 0, len(imageMap))
+	images := make([]*composeImage, 0, len(imageMap))
 	for _, item := range imageMap {
+		sort.Strings(item.Services)
 		images = append(images, item)
 	}
 
@@ -353,6 +503,54 @@ func DockerComposeProxy(proxyList []models.ProxyItem) {
 	sort.Slice(images, func(i, j int) bool {
 		return images[i].Image < images[j].Image
 	})
+	return images, nil
+}
+
+// DockerComposeProxy 处理 docker-compose 命令的代理
+// 指定 service 名称时只拉取这些 service 的镜像，不再提示选择
+// proxyList: 代理服务列表，按优先顺序排列，拉取失败时依次切换
+func DockerComposeProxy(proxyList []models.ProxyItem) {
+	if len(proxyList) == 0 {
+		fmt.Fprintln(os.Stderr, "错误: 未找到可用的代理服务")
+		os.Exit(1)
+	}
+
+	// cnfast docker compose [service...] 或 cnfast docker-compose [service...]
+	services := os.Args[2:]
+	if os.Args[1] == "docker" {
+		services = os.Args[3:]
+	}
+
+	composeFile := findComposeFile()
+	if composeFile == "" {
+		fmt.Fprintln(os.Stderr, "错误: 当前目录未找到 docker compose 配置文件 (docker-compose.yml|docker-compose.yaml|compose.yml|compose.yaml)")
+		os.Exit(1)
+	}
+
+	if config.Debug {
+		fmt.Printf("使用 compose 文件: %s\n", composeFile)
+	}
+
+	images, err := loadComposeImages(composeFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(images) == 0 {
+		fmt.Println("未在 compose 配置中找到任何需要拉取的镜像")
+		return
+	}
+
+	if len(services) > 0 {
+		selected, err := composeServiceImages(images, services)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		pullComposeImages(selected, proxyList)
+		return
+	}
 
 	fmt.Println("发现以下镜像:")
 	for i, item := range images {
@@ -388,35 +586,40 @@ func DockerComposeProxy(proxyList []models.ProxyItem) {
 		}
 	}
 
-	// 对选中的镜像逐个执行加速拉取
+	// 对选中的镜像执行加速拉取
+	selected := make([]string, 0, len(indices))
 	for _, idx := range indices {
-		item := images[idx]
-		original := item.Image
-		accelerated := replaceImageWithSpecificDomain(original)
-
-		needRetagging := false
-		if accelerated != original {
-			fmt.Printf("\n镜像加速: %s -> %s\n", original, accelerated)
-			needRetagging = true
-		}
-
-		if config.Debug {
-			fmt.Printf("执行命令: docker pull %s\n", accelerated)
-		}
+		selected = append(selected, images[idx].Image)
+	}
 
-		pullCmd := exec.Command("docker", "pull", accelerated)
-		pullCmd.Stdin = os.Stdin
-		pullCmd.Stdout = os.Stdout
-		pullCmd.Stderr = os.Stderr
+	pullComposeImages(selected, proxyList)
+}
 
-		if err := pullCmd.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "拉取镜像失败 (%s): %v\n", original, err)
-			// 失败时继续尝试下一个镜像
-			continue
+// composeServiceImages 返回指定 service 使用的镜像，service 不存在或没有镜像时返回错误
+func composeServiceImages(images []*composeImage, services []string) ([]string, error) {
+	var selected []string
+	for _, service := range services {
+		found := false
+		for _, item := range images {
+			if isCommandSupported(service, item.Services) {
+				selected = append(selected, item.Image)
+				found = true
+			}
 		}
-
-		if needRetagging {
-			retagImage(accelerated, original)
+		if !found {
+			return nil, fmt.Errorf("compose 配置中没有使用镜像的 service '%s'", service)
 		}
 	}
+	return selected, nil
+}
+
+// pullComposeImages 加速拉取选中的 compose 镜像，有镜像失败时退出
+func pullComposeImages(selected []string, proxyList []models.ProxyItem) {
+	useImageLock()
+	results := pullWithFailover(uniqueImages(selected), proxyList, func(pending []string) []pullResult {
+		return pullImages(pending, nil, config.PullConcurrency)
+	})
+	if printPullReport(results) > 0 {
+		os.Exit(1)
+	}
 }
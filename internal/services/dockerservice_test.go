@@ -0,0 +1,60 @@
+package services
+
+import (
+	"cnfast/config"
+
+	"testing"
+)
+
+// useEmptyUserConfig 在测试期间忽略本机的用户配置文件，结束后恢复
+func useEmptyUserConfig(t *testing.T) {
+	t.Helper()
+	saved := config.User
+	config.User = &config.UserConfig{}
+	t.Cleanup(func() {
+		config.User = saved
+	})
+}
+
+// useTestRegistryMapping 在测试期间使用指定的加速映射，结束后恢复默认映射
+func useTestRegistryMapping(t *testing.T, domain string, proxyTemplates map[string]string, imageTemplate string) {
+	t.Helper()
+	useEmptyUserConfig(t)
+	SetRegistryMapping(domain, proxyTemplates, imageTemplate)
+	t.Cleanup(func() {
+		SetRegistryMapping(defaultAccelDomain, nil, "")
+	})
+}
+
+func TestReplaceImageWithSpecificDomain(t *testing.T) {
+	const digest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name          string
+		proxy         map[string]string
+		imageTemplate string
+		image         string
+		want          string
+	}{
+		{"子域名模板", nil, "", "nginx:1.25", "accel.example.com/library/nginx:1.25"},
+		{"子域名模板 ghcr", nil, "", "ghcr.io/owner/app:v1", "ghcr.accel.example.com/owner/app:v1"},
+		{"路径模板", nil, "{proxy}/{host}", "ghcr.io/owner/app:v1", "accel.example.com/ghcr.io/owner/app:v1"},
+		{"完整地址模板", nil, "{proxy}/{host}/{path}", "quay.io/org/app", "accel.example.com/quay.io/org/app"},
+		{"完整地址模板带摘要", nil, "{proxy}/v/{path}", "nginx:1.25@" + digest, "accel.example.com/v/library/nginx@" + digest},
+		{"代理下发映射覆盖内置", map[string]string{"ghcr.io": "{proxy}/gh"}, "", "ghcr.io/owner/app", "accel.example.com/gh/owner/app"},
+		{"代理下发新镜像源", map[string]string{"harbor.corp.com": "harbor.{proxy}"}, "", "harbor.corp.com/team/app:1", "harbor.accel.example.com/team/app:1"},
+		{"空模板不加速", map[string]string{"ghcr.io": ""}, "", "ghcr.io/owner/app", "ghcr.io/owner/app"},
+		{"未知镜像源不改写", nil, "", "myregistry.local:5000/app:1.0", "myregistry.local:5000/app:1.0"},
+		{"localhost 不改写", nil, "", "localhost/app", "localhost/app"},
+		{"无法解析时不改写", nil, "", "Bad/Image", "Bad/Image"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTestRegistryMapping(t, "accel.example.com", tt.proxy, tt.imageTemplate)
+			if got := replaceImageWithSpecificDomain(tt.image); got != tt.want {
+				t.Errorf("replaceImageWithSpecificDomain(%q) = %q, 期望 %q", tt.image, got, tt.want)
+			}
+		})
+	}
+}
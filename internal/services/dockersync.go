@@ -0,0 +1,237 @@
+// Package services 包含镜像同步到内部仓库的逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/registry"
+	"cnfast/internal/pkg/util"
+
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// syncJobSeparator 同步任务中源镜像与目标镜像的分隔符，同时用于进度输出
+const syncJobSeparator = " -> "
+
+// imageSyncer 在两个 registry 之间复制镜像
+// 通过 Registry v2 API 直接传输 blob，不经过本地 Docker
+type imageSyncer struct {
+	// src 源仓库客户端，访问加速域名
+	src     *registry.Client
+	srcHost string
+	srcRepo string
+
+	// dst 目标仓库客户端
+	dst     *registry.Client
+	dstHost string
+	dstRepo string
+
+	// out 输出目标
+	out io.Writer
+}
+
+// dockerSyncImages 处理 cnfast docker sync 命令
+// 经加速域名读取镜像并推送到内部仓库，多平台清单列表保持不变
+// args: sync 之后的全部参数
+// proxyList: 按优先顺序排列的代理列表
+func dockerSyncImages(args []string, proxyList []models.ProxyItem) {
+	listFile, args, hasFile := util.ExtractFlagValue(args, "-f", "--file")
+	parallel, args, hasParallel := util.ExtractFlagValue(args, "-j", "--parallel")
+	plainHTTP, args := util.ExtractBoolFlag(args, "--plain-http")
+
+	concurrency := config.PullConcurrency
+	if hasParallel {
+		n, err := strconv.Atoi(parallel)
+		if err != nil || n < 1 {
+			fmt.Fprintf(os.Stderr, "错误: 无效的并发数: %s\n", parallel)
+			os.Exit(1)
+		}
+		concurrency = n
+	}
+
+	var jobs []string
+	switch len(args) {
+	case 0:
+	case 2:
+		jobs = append(jobs, args[0]+syncJobSeparator+args[1])
+	default:
+		printSyncUsage()
+		os.Exit(1)
+	}
+
+	if hasFile {
+		lines, err := readImageListFile(listFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		for _, line := range lines {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				fmt.Fprintf(os.Stderr, "错误: 同步列表格式无效，每行应为 \"源镜像 目标镜像\": %s\n", line)
+				os.Exit(1)
+			}
+			jobs = append(jobs, fields[0]+syncJobSeparator+fields[1])
+		}
+	}
+
+	jobs = uniqueStrings(jobs)
+	if len(jobs) == 0 {
+		printSyncUsage()
+		os.Exit(1)
+	}
+
+	useImageLock()
+	results := jobsWithFailover(jobs, proxyList, "同步", func(pending []string) []pullResult {
+		return runJobs(pending, concurrency, "同步", func(job string, out io.Writer) error {
+			parts := strings.SplitN(job, syncJobSeparator, 2)
+			return syncImage(parts[0], parts[1], plainHTTP, out)
+		})
+	})
+	if printJobReport(results, "同步") > 0 {
+		os.Exit(1)
+	}
+}
+
+// printSyncUsage 输出 sync 命令用法
+func printSyncUsage() {
+	fmt.Fprintln(os.Stderr, "用法: cnfast docker sync <源镜像> <目标仓库/镜像:标签> [--plain-http]")
+	fmt.Fprintln(os.Stderr, "      cnfast docker sync -f 同步列表文件 [-j 并发数] [--plain-http]")
+	fmt.Fprintln(os.Stderr, "同步列表文件每行为 \"源镜像 目标镜像\"，支持 # 注释")
+}
+
+// syncImage 将源镜像经加速域名复制到目标仓库
+// src: 源镜像名
+// dst: 目标镜像名，未指定标签时沿用源镜像的标签
+// plainHTTP: 是否使用 HTTP 访问目标仓库
+func syncImage(src, dst string, plainHTTP bool, out io.Writer) error {
+	srcRef, err := reference.Parse(src)
+	if err != nil {
+		return err
+	}
+	dstRef, err := reference.Parse(dst)
+	if err != nil {
+		return err
+	}
+	if dstRef.Digest != "" {
+		return fmt.Errorf("目标镜像不能指定摘要: %s", dst)
+	}
+
+	dstTag := dstRef.Tag
+	if dstTag == "" {
+		dstTag = srcRef.Tag
+	}
+	if dstTag == "" && srcRef.Digest != "" {
+		return fmt.Errorf("源镜像只指定了摘要，请为目标镜像指定标签")
+	}
+	if dstTag == "" {
+		dstTag = "latest"
+	}
+
+	srcHost, srcRepo, target, err := remoteTarget(src)
+	if err != nil {
+		return err
+	}
+	if srcHost != registry.APIHost(srcRef.Domain) {
+		fmt.Fprintf(out, "镜像加速: %s -> %s/%s\n", src, srcHost, srcRepo)
+	}
+
+	syncer := &imageSyncer{
+		src:     registry.NewClient(registryAuthFunc(src)),
+		srcHost: srcHost,
+		srcRepo: srcRepo,
+		dst:     registry.NewClient(registryAuthFunc(dst)),
+		dstHost: registry.APIHost(dstRef.Domain),
+		dstRepo: dstRef.Path,
+		out:     out,
+	}
+	if plainHTTP {
+		syncer.dst.UsePlainHTTP(syncer.dstHost)
+	}
+
+	ctx := context.Background()
+	manifest, err := syncer.src.GetManifest(ctx, srcHost, srcRepo, target)
+	if err != nil {
+		return err
+	}
+
+	if locked := activeImageLock.find(src); locked != nil && locked.Digest != manifest.Digest {
+		return fmt.Errorf("镜像摘要与锁文件不一致（期望 %s，实际 %s）", locked.Digest, manifest.Digest)
+	}
+
+	if err := syncer.copyManifestContent(ctx, manifest); err != nil {
+		return err
+	}
+	if err := syncer.dst.PutManifest(ctx, syncer.dstHost, syncer.dstRepo, dstTag, manifest); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "已同步 %s -> %s/%s:%s (%s)\n", src, dstRef.Domain, dstRef.Path, dstTag, shortDigest(manifest.Digest))
+	return nil
+}
+
+// copyManifestContent 复制清单引用的全部内容
+// 清单列表逐个复制各平台清单（按摘要推送），单平台清单复制配置与各层
+func (s *imageSyncer) copyManifestContent(ctx context.Context, manifest *registry.Manifest) error {
+	if manifest.IsIndex() {
+		for _, desc := range manifest.Manifests {
+			child, err := s.src.GetManifest(ctx, s.srcHost, s.srcRepo, desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := s.copyManifestContent(ctx, child); err != nil {
+				return err
+			}
+			if err := s.dst.PutManifest(ctx, s.dstHost, s.dstRepo, desc.Digest, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	blobs := manifest.Layers
+	if manifest.Config != nil {
+		blobs = append([]registry.Descriptor{*manifest.Config}, blobs...)
+	}
+	for _, blob := range blobs {
+		if err := s.copyBlob(ctx, blob); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyBlob 复制单个 blob，目标仓库已存在时跳过
+func (s *imageSyncer) copyBlob(ctx context.Context, blob registry.Descriptor) error {
+	// 不可分发的层由客户端从外部地址下载，registry 中不存在
+	if len(blob.URLs) > 0 {
+		return nil
+	}
+
+	exists, err := s.dst.BlobExists(ctx, s.dstHost, s.dstRepo, blob.Digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		fmt.Fprintf(s.out, "跳过已存在的 blob %s\n", shortDigest(blob.Digest))
+		return nil
+	}
+
+	fmt.Fprintf(s.out, "复制 blob %s (%s)\n", shortDigest(blob.Digest), util.FormatSize(blob.Size))
+	content, size, err := s.src.OpenBlob(ctx, s.srcHost, s.srcRepo, blob.Digest)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	if size < 0 {
+		size = blob.Size
+	}
+	return s.dst.PushBlob(ctx, s.dstHost, s.dstRepo, blob.Digest, size, content)
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+// +build !windows
+
+// Package services 包含以外部命令替换当前进程的逻辑
+package services
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// execCommand 以外部命令替换当前进程，信号、终端与退出码都由该命令直接处理
+// 执行失败时输出错误并退出，成功时不会返回
+func execCommand(name string, args []string) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 未找到 %s 命令: %v\n", name, err)
+		os.Exit(1)
+	}
+
+	err = syscall.Exec(path, append([]string{name}, args...), os.Environ())
+	fmt.Fprintf(os.Stderr, "错误: 执行 %s 失败: %v\n", name, err)
+	os.Exit(1)
+}
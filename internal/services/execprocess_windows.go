@@ -0,0 +1,32 @@
+// Package services 包含以外部命令替换当前进程的逻辑
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+// execCommand 执行外部命令并以其退出码退出
+// Windows 不支持替换进程，改为启动子进程；Ctrl+C 由子进程处理，当前进程只等待其结束
+func execCommand(name string, args []string) {
+	signal.Ignore(os.Interrupt)
+
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.ExitCode())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 执行 %s 失败: %v\n", name, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,342 @@
+// Package services 包含 git-remote-cnfast 远程助手逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/models"
+
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitRemoteHelperName 以 git 远程助手方式调用时的程序名
+const gitRemoteHelperName = "git-remote-cnfast"
+
+// gitRemoteProbeTimeout 探测单个代理的超时时间
+const gitRemoteProbeTimeout = 5 * time.Second
+
+// IsGitRemoteHelper 判断当前是否以 git-remote-cnfast 的名义被调用
+// 将 cnfast 链接或复制为 PATH 中的 git-remote-cnfast 后，git 会对 cnfast:: 地址调用它
+func IsGitRemoteHelper() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return name == gitRemoteHelperName
+}
+
+// GitRemoteHelper 实现 cnfast::<url> 地址的 git 远程助手
+// git 以 git-remote-cnfast <远程名> <url> 调用，助手通过标准输入输出与 git 交互；
+// 实际的传输交给 git remote-https 完成，GitHub 地址的拉取经可用的代理，推送直接访问原始地址
+// loadProxies: 获取 git 代理列表
+func GitRemoteHelper(loadProxies func() ([]models.ProxyItem, error)) {
+	// 标准输出只能包含协议内容，其余输出（包括调试信息）全部写入标准错误
+	protocolOut := os.Stdout
+	os.Stdout = os.Stderr
+
+	if len(os.Args) < 3 {
+		fmt.Fprintln(os.Stderr, "用法: git clone cnfast::https://github.com/owner/repo")
+		fmt.Fprintf(os.Stderr, "%s 由 git 调用，不需要直接执行\n", gitRemoteHelperName)
+		os.Exit(1)
+	}
+
+	remote, target := os.Args[1], os.Args[2]
+	// 同时支持 cnfast://github.com/owner/repo 写法
+	if strings.HasPrefix(target, "cnfast://") {
+		target = "https://" + strings.TrimPrefix(target, "cnfast://")
+	}
+
+	fetchURLs := []string{target}
+	if isGitHubURL(target) {
+		proxyList, err := loadProxies()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 获取 Git 代理服务失败，直接访问 GitHub: %v\n", err)
+		} else {
+			fetchURLs = probeGitProxies(sortProxiesByScore(proxyList), target)
+		}
+	}
+
+	relay := &gitRemoteRelay{
+		remote:    remote,
+		fetchURLs: fetchURLs,
+		pushURL:   target,
+		in:        bufio.NewReader(os.Stdin),
+		out:       protocolOut,
+	}
+	if err := relay.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// probeGitProxies 按顺序探测代理，返回拉取时依次尝试的地址
+// 跳过探测失败的代理，从第一个可用代理开始，其后的代理作为备用，原始地址排在最后；
+// 代理返回 200 或 401（私有仓库需要认证）均视为可用
+func probeGitProxies(proxyList []models.ProxyItem, target string) []string {
+	client := &http.Client{Timeout: gitRemoteProbeTimeout}
+	probePath := strings.TrimSuffix(target, "/") + "/info/refs?service=git-upload-pack"
+
+	for i, proxy := range proxyList {
+		probeURL := renderGitURL(proxy, probePath)
+		ctx, cancel := context.WithTimeout(context.Background(), gitRemoteProbeTimeout)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL, nil)
+		if err != nil {
+			cancel()
+			continue
+		}
+
+		resp, err := client.Do(req)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "代理 %s 不可用: %v\n", proxy.ProxyUrl, err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusUnauthorized {
+			var urls []string
+			for _, candidate := range proxyList[i:] {
+				urls = append(urls, renderGitURL(candidate, target))
+			}
+			if config.Debug {
+				fmt.Fprintf(os.Stderr, "URL 加速: %s -> %s\n", target, urls[0])
+			}
+			return append(urls, target)
+		}
+		fmt.Fprintf(os.Stderr, "代理 %s 不可用，HTTP 状态码: %d\n", proxy.ProxyUrl, resp.StatusCode)
+	}
+
+	fmt.Fprintln(os.Stderr, "警告: 没有可用的 Git 代理，直接访问 GitHub")
+	return []string{target}
+}
+
+// gitRemoteRelay 在 git 与 git remote-https 之间转发远程助手协议
+// 拉取阶段按命令转发（capabilities、option、list、fetch），子进程未完成响应就退出时，
+// 改用下一个地址重新启动，重放之前的 capabilities 与 option 命令后重试当前命令；
+// 收到推送命令时改为连接原始地址，之后的交互直接转发
+type gitRemoteRelay struct {
+	// remote 远程名
+	remote string
+
+	// fetchURLs 拉取依次尝试的地址，最后一个为原始地址
+	fetchURLs []string
+
+	// current 当前使用的拉取地址下标
+	current int
+
+	// pushURL 推送使用的原始地址
+	pushURL string
+
+	// in、out 与 git 通信的输入输出
+	in  *bufio.Reader
+	out io.Writer
+
+	// preamble 已转发的 capabilities 与 option 命令
+	preamble []string
+
+	// child 当前的 git remote-https 子进程
+	child *gitRemoteChild
+}
+
+// gitRemoteChild 运行中的 git remote-https 子进程
+type gitRemoteChild struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   *bufio.Reader
+}
+
+// run 处理 git 发来的命令，直到会话结束或转为直接转发
+func (r *gitRemoteRelay) run() error {
+	var err error
+	if r.child, err = r.start(r.fetchURLs[0]); err != nil {
+		return err
+	}
+
+	for {
+		line, err := r.in.ReadString('\n')
+		if err != nil {
+			return r.child.close()
+		}
+		command := strings.TrimSpace(line)
+
+		switch {
+		case command == "":
+			return r.child.close()
+		case command == "capabilities" || strings.HasPrefix(command, "option "):
+			reply, err := r.child.request([]string{line})
+			if err != nil {
+				return err
+			}
+			r.preamble = append(r.preamble, line)
+			if command == "capabilities" {
+				reply = r.filterCapabilities(reply)
+			}
+			if _, err := io.WriteString(r.out, reply); err != nil {
+				return err
+			}
+		case command == "list" || strings.HasPrefix(command, "fetch "):
+			// fetch 命令成批发送，以空行结束
+			batch := []string{line}
+			for command != "list" {
+				next, err := r.in.ReadString('\n')
+				if err != nil {
+					return err
+				}
+				batch = append(batch, next)
+				if strings.TrimSpace(next) == "" {
+					break
+				}
+			}
+			reply, err := r.fetchRequest(batch, command != "list")
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(r.out, reply); err != nil {
+				return err
+			}
+		default:
+			if command == "list for-push" && r.pushURL != r.fetchURLs[r.current] {
+				// 推送直接访问原始地址，代理通常不支持推送
+				if err := r.reconnect(r.pushURL, false); err != nil {
+					return err
+				}
+			}
+			// 推送及其他命令之后的交互全部直接转发
+			if _, err := io.WriteString(r.child.stdin, line); err != nil {
+				return err
+			}
+			return r.child.pipe(r.in, r.out)
+		}
+	}
+}
+
+// filterCapabilities 存在备用地址时去掉 stateless-connect 与 connect
+// 使 git 使用 list/fetch 命令拉取，失败时可以在命令之间切换地址；
+// 建立连接后的交互是连续的数据流，无法中途切换
+func (r *gitRemoteRelay) filterCapabilities(reply string) string {
+	if len(r.fetchURLs) < 2 {
+		return reply
+	}
+	var lines []string
+	for _, capability := range strings.SplitAfter(reply, "\n") {
+		switch strings.TrimSpace(capability) {
+		case "stateless-connect", "connect":
+			continue
+		}
+		lines = append(lines, capability)
+	}
+	return strings.Join(lines, "")
+}
+
+// fetchRequest 发送拉取阶段的命令并返回完整的响应
+// 当前地址失败时依次切换到后续地址重试；needsList 为 true 时切换后先发送 list，
+// 与 git 的命令顺序保持一致
+func (r *gitRemoteRelay) fetchRequest(lines []string, needsList bool) (string, error) {
+	reply, err := r.child.request(lines)
+	for err != nil && r.current+1 < len(r.fetchURLs) {
+		r.current++
+		fmt.Fprintf(os.Stderr, "拉取失败，切换到 %s 重试\n", r.fetchURLs[r.current])
+		if err = r.reconnect(r.fetchURLs[r.current], needsList); err != nil {
+			fmt.Fprintf(os.Stderr, "连接 %s 失败: %v\n", r.fetchURLs[r.current], err)
+			continue
+		}
+		reply, err = r.child.request(lines)
+	}
+	return reply, err
+}
+
+// reconnect 关闭当前子进程，连接新地址并重放之前的 capabilities 与 option 命令
+// withList: 是否在重放后发送 list（丢弃响应）
+func (r *gitRemoteRelay) reconnect(url string, withList bool) error {
+	if r.child != nil {
+		r.child.close()
+	}
+
+	var err error
+	if r.child, err = r.start(url); err != nil {
+		return err
+	}
+	replay := r.preamble
+	if withList {
+		replay = append(append([]string{}, r.preamble...), "list\n")
+	}
+	for _, previous := range replay {
+		if _, err := r.child.request([]string{previous}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// start 启动连接指定地址的 git remote-https
+func (r *gitRemoteRelay) start(url string) (*gitRemoteChild, error) {
+	args := []string{"remote-https", r.remote, url}
+	if len(r.fetchURLs) > 1 {
+		// 去掉 stateless-connect 后 list 只能处理 v0 协议的引用列表
+		args = append([]string{"-c", "protocol.version=0"}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("启动 git remote-https 失败: %w", err)
+	}
+	return &gitRemoteChild{cmd: cmd, stdin: stdin, out: bufio.NewReader(stdout)}, nil
+}
+
+// request 发送命令并读取完整的响应
+// option 的响应只有一行，其他命令（capabilities、list、fetch）的响应以空行结束；
+// 子进程在响应完成前退出时返回错误，已读取的部分不会写给 git
+func (c *gitRemoteChild) request(lines []string) (string, error) {
+	for _, line := range lines {
+		if _, err := io.WriteString(c.stdin, line); err != nil {
+			return "", fmt.Errorf("git remote-https 意外退出: %w", err)
+		}
+	}
+
+	singleLine := strings.HasPrefix(lines[0], "option ")
+	var reply strings.Builder
+	for {
+		line, err := c.out.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("git remote-https 意外退出: %w", err)
+		}
+		reply.WriteString(line)
+		if singleLine || line == "\n" {
+			return reply.String(), nil
+		}
+	}
+}
+
+// close 发送表示会话结束的空行，关闭输入并等待子进程退出
+func (c *gitRemoteChild) close() error {
+	io.WriteString(c.stdin, "\n")
+	c.stdin.Close()
+	return c.cmd.Wait()
+}
+
+// pipe 双向转发剩余的交互，直到子进程退出
+func (c *gitRemoteChild) pipe(in io.Reader, out io.Writer) error {
+	go func() {
+		io.Copy(c.stdin, in)
+		c.stdin.Close()
+	}()
+
+	if _, err := io.Copy(out, c.out); err != nil {
+		return err
+	}
+	return c.cmd.Wait()
+}
@@ -6,6 +6,7 @@ import (
 	"cnfast/internal/models"
 	"cnfast/internal/pkg/util"
 	"fmt"
+	"net/url"
 	"os"
 	"os/exec"
 	"regexp"
@@ -21,6 +22,9 @@ var (
 
 	// proxyPrefix 代理服务前缀
 	proxyPrefix = "https://proxy.pipers.cn/"
+
+	// defaultGitURLTemplate 默认的加速地址模板：代理地址后直接拼接原始地址
+	defaultGitURLTemplate = "{proxy}/{url}"
 )
 
 // GitProxy 执行 Git 命令并应用 GitHub 加速
@@ -63,7 +67,7 @@ func executeGitWithProxyRetry(proxyList []models.ProxyItem, command string) {
 	// 使用通用的代理重试框架
 	ExecuteWithProxyRetry(proxyList, func(proxy models.ProxyItem) (*exec.Cmd, string, error) {
 		// 构建加速后的参数
-		newArgs := buildGitArgs(proxy.ProxyUrl, command)
+		newArgs := buildGitArgs(proxy, command)
 
 		if config.Debug {
 			fmt.Printf("执行命令: git %s\n", strings.Join(newArgs, " "))
@@ -115,13 +119,45 @@ func selectProxyWithPrompt(proxyList []models.ProxyItem) models.ProxyItem {
 	return selected
 }
 
+// gitURLTemplate 返回代理使用的加速地址模板
+// 优先级: 用户配置 > 代理服务下发 > 默认模板
+func gitURLTemplate(proxy models.ProxyItem) string {
+	if config.User.GitURLTemplate != "" {
+		return config.User.GitURLTemplate
+	}
+	if proxy.URLTemplate != "" {
+		return proxy.URLTemplate
+	}
+	return defaultGitURLTemplate
+}
+
+// renderGitURL 按代理的地址模板生成加速地址
+// 模板支持的占位符:
+//   {proxy}  代理地址（去除末尾的 /）
+//   {url}    完整的原始地址
+//   {scheme} 原始地址的协议
+//   {host}   原始地址的主机名
+//   {path}   原始地址的路径（不含开头的 /，包含查询参数）
+func renderGitURL(proxy models.ProxyItem, original string) string {
+	vars := map[string]string{
+		"proxy": strings.TrimRight(proxy.ProxyUrl, "/"),
+		"url":   original,
+	}
+	if parsed, err := url.Parse(original); err == nil {
+		vars["scheme"] = parsed.Scheme
+		vars["host"] = parsed.Host
+		vars["path"] = strings.TrimPrefix(parsed.RequestURI(), "/")
+	}
+	return util.RenderTemplate(gitURLTemplate(proxy), vars)
+}
+
 // buildGitArgs 构建 Git 命令参数
-func buildGitArgs(proxyUrl, command string) []string {
+func buildGitArgs(proxy models.ProxyItem, command string) []string {
 	newArgs := []string{}
 	for _, arg := range os.Args[2:] {
 		// 如果是 GitHub URL，进行加速替换
 		if isGitHubURL(arg) {
-			acceleratedURL := proxyUrl + "/" + arg
+			acceleratedURL := renderGitURL(proxy, arg)
 			if config.Debug {
 				fmt.Printf("URL 加速: %s -> %s\n", arg, acceleratedURL)
 			}
@@ -159,7 +195,7 @@ func executeDownloadWithProxyRetry(proxyList []models.ProxyItem) {
 	// 使用通用的代理重试框架
 	ExecuteWithProxyRetry(proxyList, func(proxy models.ProxyItem) (*exec.Cmd, string, error) {
 		// 构建代理后的下载地址
-		proxiedURL := proxy.ProxyUrl + "/" + downloadURL
+		proxiedURL := renderGitURL(proxy, downloadURL)
 
 		if config.Debug {
 			fmt.Printf("下载地址: %s\n", proxiedURL)
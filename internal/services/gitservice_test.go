@@ -0,0 +1,32 @@
+package services
+
+import (
+	"cnfast/internal/models"
+
+	"testing"
+)
+
+func TestRenderGitURL(t *testing.T) {
+	const original = "https://github.com/owner/repo.git"
+	useEmptyUserConfig(t)
+
+	tests := []struct {
+		template string
+		original string
+		want     string
+	}{
+		{"", original, "https://proxy.example.com/https://github.com/owner/repo.git"},
+		{"{proxy}/{url}", original, "https://proxy.example.com/https://github.com/owner/repo.git"},
+		{"{proxy}/{host}/{path}", original, "https://proxy.example.com/github.com/owner/repo.git"},
+		{"{proxy}/gh/{path}", original, "https://proxy.example.com/gh/owner/repo.git"},
+		{"{proxy}/gh/{path}", "https://github.com/owner/repo/archive/main.zip?x=1", "https://proxy.example.com/gh/owner/repo/archive/main.zip?x=1"},
+		{"{scheme}://{host}.proxy.example.com/{path}", original, "https://github.com.proxy.example.com/owner/repo.git"},
+	}
+
+	for _, tt := range tests {
+		proxy := models.ProxyItem{ProxyUrl: "https://proxy.example.com/", URLTemplate: tt.template}
+		if got := renderGitURL(proxy, tt.original); got != tt.want {
+			t.Errorf("renderGitURL(%q, %q) = %q, 期望 %q", tt.template, tt.original, got, tt.want)
+		}
+	}
+}
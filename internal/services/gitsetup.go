@@ -0,0 +1,349 @@
+// Package services 包含持久化 git insteadOf 加速规则的逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/util"
+
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitSetupRecordFile git setup 修改记录文件名（位于 config.HomeDir）
+const gitSetupRecordFile = "git-setup.json"
+
+// gitHubURLPrefix insteadOf 规则替换的原始地址前缀
+const gitHubURLPrefix = "https://github.com/"
+
+// gitPushInsteadOfKey 推送时保持原始地址的规则
+// insteadOf 同样作用于推送，而代理不支持推送，因此推送地址改写为 GitHub 本身
+const gitPushInsteadOfKey = "url." + gitHubURLPrefix + ".pushInsteadOf"
+
+// gitSetupScopes 支持的 git 配置范围
+var gitSetupScopes = []string{"global", "system", "local"}
+
+// gitSetupRecord cnfast 写入的一条 insteadOf 规则
+type gitSetupRecord struct {
+	// Scope git 配置范围: global、system 或 local
+	Scope string `json:"scope"`
+
+	// Repo local 范围对应的仓库根目录
+	Repo string `json:"repo,omitempty"`
+
+	// ProxyID 使用的代理 ID
+	ProxyID string `json:"proxyId"`
+
+	// ProxyURL 使用的代理地址
+	ProxyURL string `json:"proxyUrl"`
+
+	// Key 写入的配置项，如 url.https://proxy/https://github.com/.insteadOf
+	Key string `json:"key"`
+
+	// Value 配置值，即被替换的原始地址前缀
+	Value string `json:"value"`
+
+	// PushKey 同时写入的 pushInsteadOf 配置项，值与 Value 相同，使推送直接访问 GitHub
+	PushKey string `json:"pushKey,omitempty"`
+
+	// UpdatedAt 写入时间
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// gitSetupFile git setup 修改记录
+type gitSetupFile struct {
+	Records []gitSetupRecord `json:"records"`
+}
+
+// GitSetup 处理 cnfast git setup 命令
+// 将 GitHub 地址的 insteadOf 规则写入 git 配置，使 IDE、go get、cargo、pip 等工具直接经代理访问
+// args: setup 之后的全部参数
+// loadProxies: 获取 git 代理列表，只在需要写入规则时调用
+func GitSetup(args []string, loadProxies func() ([]models.ProxyItem, error)) {
+	scope, args, hasScope := util.ExtractFlagValue(args, "--scope")
+	switchID, args, hasSwitch := util.ExtractFlagValue(args, "--switch")
+	revert, args := util.ExtractBoolFlag(args, "--revert")
+
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的参数 '%s'\n", strings.Join(args, " "))
+		printGitSetupUsage()
+		os.Exit(1)
+	}
+	if hasScope && !isCommandSupported(scope, gitSetupScopes) {
+		fmt.Fprintf(os.Stderr, "错误: 无效的配置范围 '%s'\n", scope)
+		printGitSetupUsage()
+		os.Exit(1)
+	}
+	if revert && hasSwitch {
+		fmt.Fprintln(os.Stderr, "错误: --revert 与 --switch 不能同时使用")
+		os.Exit(1)
+	}
+
+	records, err := loadGitSetupFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	if revert {
+		// 未指定范围时撤销全部记录
+		if err := revertGitSetup(records, scope); err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !hasScope {
+		scope = "global"
+	}
+	repo := ""
+	if scope == "local" {
+		output, err := exec.Command("git", "rev-parse", "--show-toplevel").Output()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "错误: --scope local 需要在 git 仓库中执行")
+			os.Exit(1)
+		}
+		repo = strings.TrimSpace(string(output))
+	}
+
+	proxyList, err := loadProxies()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: 获取 Git 代理服务失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	var proxy models.ProxyItem
+	if hasSwitch {
+		found := false
+		for _, item := range proxyList {
+			if item.ID == switchID {
+				proxy, found = item, true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(os.Stderr, "错误: 未找到 ID 为 %s 的代理，可用的代理:\n", switchID)
+			for _, item := range sortProxiesByScore(proxyList) {
+				fmt.Fprintf(os.Stderr, "  %-12s %s\n", item.ID, item.ProxyUrl)
+			}
+			os.Exit(1)
+		}
+	} else {
+		proxy = selectProxyWithPrompt(proxyList)
+	}
+
+	if err := applyGitSetup(records, scope, repo, proxy); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// applyGitSetup 写入（或替换）指定范围的 insteadOf 规则并更新记录
+func applyGitSetup(records *gitSetupFile, scope, repo string, proxy models.ProxyItem) error {
+	prefix, err := gitInsteadOfPrefix(proxy)
+	if err != nil {
+		return err
+	}
+	key := "url." + prefix + ".insteadOf"
+
+	// 同一范围已有记录时先移除旧规则，即切换代理
+	index := records.find(scope, repo)
+	if index >= 0 {
+		old := records.Records[index]
+		if old.Key == key && old.PushKey == gitPushInsteadOfKey {
+			fmt.Printf("%s 范围已使用代理 %s，无需修改\n", scope, proxy.ProxyUrl)
+			return nil
+		}
+		if err := unsetGitSetupRule(old); err != nil {
+			return err
+		}
+		fmt.Printf("已移除旧规则: %s\n", old.Key)
+	}
+
+	warnConflictingInsteadOf(scope, repo, key)
+
+	record := gitSetupRecord{
+		Scope:     scope,
+		Repo:      repo,
+		ProxyID:   proxy.ID,
+		ProxyURL:  proxy.ProxyUrl,
+		Key:       key,
+		Value:     gitHubURLPrefix,
+		PushKey:   gitPushInsteadOfKey,
+		UpdatedAt: time.Now().Format(time.RFC3339),
+	}
+	if output, err := gitConfigCmd(scope, repo, key, gitHubURLPrefix).CombinedOutput(); err != nil {
+		return fmt.Errorf("写入 git 配置失败: %v\n%s", err, output)
+	}
+	current, _ := gitConfigCmd(scope, repo, "--get-all", gitPushInsteadOfKey).Output()
+	if !containsLine(string(current), gitHubURLPrefix) {
+		if output, err := gitConfigCmd(scope, repo, "--add", gitPushInsteadOfKey, gitHubURLPrefix).CombinedOutput(); err != nil {
+			unsetGitConfigValue(scope, repo, key, gitHubURLPrefix)
+			return fmt.Errorf("写入 git 配置失败: %v\n%s", err, output)
+		}
+	}
+
+	if index >= 0 {
+		records.Records[index] = record
+	} else {
+		records.Records = append(records.Records, record)
+	}
+	if err := saveGitSetupFile(records); err != nil {
+		return err
+	}
+
+	fmt.Printf("已写入 %s 范围的 git 配置: %s = %s\n", scope, key, gitHubURLPrefix)
+	fmt.Printf("推送保持直接访问 GitHub: %s = %s\n", gitPushInsteadOfKey, gitHubURLPrefix)
+	fmt.Println("撤销: cnfast git setup --revert；切换代理: cnfast git setup --switch <代理 ID>")
+	return nil
+}
+
+// revertGitSetup 撤销 cnfast 写入的规则
+// scope: 只撤销该范围的规则，为空时撤销全部
+func revertGitSetup(records *gitSetupFile, scope string) error {
+	kept := make([]gitSetupRecord, 0, len(records.Records))
+	reverted := 0
+	for _, record := range records.Records {
+		if scope != "" && record.Scope != scope {
+			kept = append(kept, record)
+			continue
+		}
+		if err := unsetGitSetupRule(record); err != nil {
+			return err
+		}
+		fmt.Printf("已撤销 %s 范围的规则: %s\n", record.Scope, record.Key)
+		reverted++
+	}
+
+	if reverted == 0 {
+		fmt.Println("没有需要撤销的 git setup 规则")
+		return nil
+	}
+	records.Records = kept
+	return saveGitSetupFile(records)
+}
+
+// unsetGitSetupRule 删除一条记录写入的 insteadOf 与 pushInsteadOf 规则
+func unsetGitSetupRule(record gitSetupRecord) error {
+	if err := unsetGitConfigValue(record.Scope, record.Repo, record.Key, record.Value); err != nil {
+		return err
+	}
+	if record.PushKey != "" {
+		return unsetGitConfigValue(record.Scope, record.Repo, record.PushKey, record.Value)
+	}
+	return nil
+}
+
+// unsetGitConfigValue 删除配置项中的指定值；规则已被手动删除或修改时跳过
+func unsetGitConfigValue(scope, repo, key, value string) error {
+	current, err := gitConfigCmd(scope, repo, "--get-all", key).Output()
+	if err != nil || !containsLine(string(current), value) {
+		fmt.Printf("规则 %s 已不存在，跳过\n", key)
+		return nil
+	}
+
+	output, err := gitConfigCmd(scope, repo, "--unset", key, "^"+strings.ReplaceAll(value, ".", `\.`)+"$").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("删除 git 配置 %s 失败: %v\n%s", key, err, output)
+	}
+	return nil
+}
+
+// warnConflictingInsteadOf 提示同一范围中替换 GitHub 地址的其他 insteadOf 规则
+func warnConflictingInsteadOf(scope, repo, key string) {
+	output, err := gitConfigCmd(scope, repo, "--get-regexp", `^url\..*\.insteadof$`).Output()
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) == 2 && fields[1] == gitHubURLPrefix && !strings.EqualFold(fields[0], key) {
+			fmt.Fprintf(os.Stderr, "警告: %s 范围中已有替换 %s 的规则 %s，可能与 cnfast 的规则冲突\n", scope, gitHubURLPrefix, fields[0])
+		}
+	}
+}
+
+// gitInsteadOfPrefix 返回代理对应的 insteadOf 前缀
+// 地址模板必须把原始路径放在末尾（如 {proxy}/{url}、{proxy}/gh/{path}），否则无法用作前缀替换
+func gitInsteadOfPrefix(proxy models.ProxyItem) (string, error) {
+	prefix := renderGitURL(proxy, gitHubURLPrefix)
+	const sample = "owner/repo.git"
+	if renderGitURL(proxy, gitHubURLPrefix+sample) != prefix+sample {
+		return "", fmt.Errorf("代理 %s 的地址模板 %s 无法用作 insteadOf 前缀", proxy.ProxyUrl, gitURLTemplate(proxy))
+	}
+	return prefix, nil
+}
+
+// gitConfigCmd 创建指定范围的 git config 命令
+func gitConfigCmd(scope, repo string, args ...string) *exec.Cmd {
+	gitArgs := []string{"config", "--" + scope}
+	if repo != "" {
+		gitArgs = append([]string{"-C", repo}, gitArgs...)
+	}
+	return exec.Command("git", append(gitArgs, args...)...)
+}
+
+// containsLine 判断多行输出中是否包含指定的行
+func containsLine(output, line string) bool {
+	for _, item := range strings.Split(output, "\n") {
+		if strings.TrimSpace(item) == line {
+			return true
+		}
+	}
+	return false
+}
+
+// find 查找指定范围（local 范围还需仓库一致）的记录，不存在时返回 -1
+func (f *gitSetupFile) find(scope, repo string) int {
+	for i, record := range f.Records {
+		if record.Scope == scope && record.Repo == repo {
+			return i
+		}
+	}
+	return -1
+}
+
+// loadGitSetupFile 读取修改记录，文件不存在时返回空记录
+func loadGitSetupFile() (*gitSetupFile, error) {
+	records := &gitSetupFile{}
+	data, err := os.ReadFile(filepath.Join(config.HomeDir, gitSetupRecordFile))
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 git setup 记录失败: %w", err)
+	}
+	if err := json.Unmarshal(data, records); err != nil {
+		return nil, fmt.Errorf("解析 git setup 记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// saveGitSetupFile 保存修改记录
+func saveGitSetupFile(records *gitSetupFile) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(config.HomeDir, 0755); err != nil {
+		return fmt.Errorf("创建数据目录失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(config.HomeDir, gitSetupRecordFile), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("保存 git setup 记录失败: %w", err)
+	}
+	return nil
+}
+
+// printGitSetupUsage 输出 git setup 命令用法
+func printGitSetupUsage() {
+	fmt.Fprintln(os.Stderr, "用法: cnfast git setup [--scope global|system|local] [--switch <代理 ID>] [--revert]")
+	fmt.Fprintln(os.Stderr, "  --scope   git 配置范围，默认 global")
+	fmt.Fprintln(os.Stderr, "  --switch  改用指定 ID 的代理，不提示选择")
+	fmt.Fprintln(os.Stderr, "  --revert  撤销 cnfast 写入的规则（未指定 --scope 时撤销全部）")
+}
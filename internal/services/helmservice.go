@@ -0,0 +1,425 @@
+// Package services 包含 Helm chart 加速逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/models"
+
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// helmLockDigestRegexp Chart.lock 中的 digest 行
+var helmLockDigestRegexp = regexp.MustCompile(`(?m)^digest: .*$`)
+
+// helmDependency Chart.yaml 中的依赖
+// 字段与 JSON 标签需与 Helm 的 chart.Dependency 保持一致，用于计算 Chart.lock 摘要
+type helmDependency struct {
+	Name         string        `json:"name" yaml:"name"`
+	Version      string        `json:"version,omitempty" yaml:"version"`
+	Repository   string        `json:"repository" yaml:"repository"`
+	Condition    string        `json:"condition,omitempty" yaml:"condition"`
+	Tags         []string      `json:"tags,omitempty" yaml:"tags"`
+	Enabled      bool          `json:"enabled,omitempty" yaml:"enabled"`
+	ImportValues []interface{} `json:"import-values,omitempty" yaml:"import-values"`
+	Alias        string        `json:"alias,omitempty" yaml:"alias"`
+}
+
+// HelmProxy 执行 Helm 命令并加速 chart 下载
+// GitHub 托管的 chart 仓库地址经 git 代理改写，oci:// 地址按镜像源映射改写
+// gitProxy: git 代理，为 nil 时不改写 GitHub 地址
+// dockerProxies: docker 代理列表，用于改写 oci:// 地址与预拉取镜像
+func HelmProxy(gitProxy *models.ProxyItem, dockerProxies []models.ProxyItem) {
+	args := os.Args[2:]
+	if len(args) == 0 {
+		printHelmUsage()
+		os.Exit(1)
+	}
+
+	if len(dockerProxies) > 0 {
+		useDockerProxy(&dockerProxies[0])
+	} else {
+		// 没有 docker 代理时 oci:// 地址保持不变
+		SetBaseAccelDomain("")
+	}
+
+	var err error
+	switch {
+	case len(args) >= 2 && args[0] == "repo" && args[1] == "add":
+		err = runHelm(rewriteHelmArgs(args, gitProxy))
+	case args[0] == "pull":
+		err = runHelm(rewriteHelmArgs(args, gitProxy))
+	case args[0] == "install":
+		err = helmInstall(args, gitProxy, dockerProxies)
+	case len(args) >= 2 && (args[0] == "dependency" || args[0] == "dep") && (args[1] == "update" || args[1] == "up"):
+		err = helmDependencyUpdate(args, gitProxy)
+	default:
+		fmt.Fprintf(os.Stderr, "错误: 不支持的 helm 命令 '%s'\n", strings.Join(args, " "))
+		printHelmUsage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "命令执行失败: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printHelmUsage 输出 helm 命令用法
+func printHelmUsage() {
+	fmt.Fprintln(os.Stderr, "用法: cnfast helm <command> [arguments]")
+	fmt.Fprintln(os.Stderr, "支持的命令: repo add, pull, install, dependency update")
+}
+
+// rewriteHelmArgs 改写参数中的 chart 仓库地址
+// 同时支持独立参数与 --repo=<url> 形式的选项
+func rewriteHelmArgs(args []string, gitProxy *models.ProxyItem) []string {
+	newArgs := make([]string, 0, len(args))
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--") {
+			if idx := strings.Index(arg, "="); idx >= 0 {
+				arg = arg[:idx+1] + rewriteChartURL(arg[idx+1:], gitProxy)
+			}
+		} else {
+			arg = rewriteChartURL(arg, gitProxy)
+		}
+		newArgs = append(newArgs, arg)
+	}
+	return newArgs
+}
+
+// rewriteChartURL 改写单个 chart 仓库地址，无需加速时原样返回
+func rewriteChartURL(raw string, gitProxy *models.ProxyItem) string {
+	accelerated := raw
+	switch {
+	case strings.HasPrefix(raw, "oci://"):
+		accelerated = "oci://" + replaceImageWithSpecificDomain(strings.TrimPrefix(raw, "oci://"))
+	case gitProxy != nil && isGitHubHostedURL(raw):
+		accelerated = renderGitURL(*gitProxy, raw)
+	}
+
+	if accelerated != raw && config.Debug {
+		fmt.Printf("URL 加速: %s -> %s\n", raw, accelerated)
+	}
+	return accelerated
+}
+
+// isGitHubHostedURL 判断地址是否托管在 GitHub（仓库、Release、raw 文件或 GitHub Pages）
+func isGitHubHostedURL(raw string) bool {
+	if isGitHubURL(raw) {
+		return true
+	}
+	match := reHost.FindStringSubmatch(raw)
+	if match == nil {
+		return false
+	}
+	host := strings.ToLower(match[1])
+	return host == "raw.githubusercontent.com" ||
+		host == "objects.githubusercontent.com" ||
+		strings.HasSuffix(host, ".github.io")
+}
+
+// runHelm 执行 helm 命令
+func runHelm(args []string) error {
+	if config.Debug {
+		fmt.Printf("执行命令: helm %s\n", strings.Join(args, " "))
+	}
+
+	cmd := exec.Command("helm", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// helmInstall 处理 helm install
+// 安装前使用 helm template 渲染 chart，并经 docker 代理预拉取其中引用的镜像
+// 使用 --no-prefetch 跳过预拉取
+func helmInstall(args []string, gitProxy *models.ProxyItem, dockerProxies []models.ProxyItem) error {
+	var noPrefetch bool
+	var installArgs []string
+	for _, arg := range args {
+		if arg == "--no-prefetch" {
+			noPrefetch = true
+			continue
+		}
+		installArgs = append(installArgs, arg)
+	}
+	installArgs = rewriteHelmArgs(installArgs, gitProxy)
+
+	dryRun := false
+	for _, arg := range installArgs {
+		if arg == "--dry-run" || strings.HasPrefix(arg, "--dry-run=") {
+			dryRun = true
+		}
+	}
+
+	if !noPrefetch && !dryRun && len(dockerProxies) > 0 {
+		prefetchHelmImages(installArgs, dockerProxies)
+	}
+
+	return runHelm(installArgs)
+}
+
+// prefetchHelmImages 渲染 chart 并预拉取其中引用的镜像
+// 预拉取失败只输出警告，不影响后续安装
+// installArgs: helm install 的参数（已改写地址）
+func prefetchHelmImages(installArgs []string, dockerProxies []models.ProxyItem) {
+	templateArgs := append([]string{"template"}, installArgs[1:]...)
+	if config.Debug {
+		fmt.Printf("执行命令: helm %s\n", strings.Join(templateArgs, " "))
+	}
+
+	cmd := exec.Command("helm", templateArgs...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 渲染 chart 失败，跳过镜像预拉取: %v\n", err)
+		return
+	}
+
+	images, err := collectManifestImages(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 解析 chart 模板失败，跳过镜像预拉取: %v\n", err)
+		return
+	}
+	if len(images) == 0 {
+		return
+	}
+
+	fmt.Printf("预拉取 chart 引用的 %d 个镜像\n", len(images))
+	results := pullWithFailover(images, dockerProxies, func(pending []string) []pullResult {
+		return pullImages(pending, nil, config.PullConcurrency)
+	})
+	if printPullReport(results) > 0 {
+		fmt.Fprintln(os.Stderr, "警告: 部分镜像预拉取失败，继续安装")
+	}
+}
+
+// collectManifestImages 收集多文档 YAML 中 image 字段引用的镜像
+func collectManifestImages(data []byte) ([]string, error) {
+	var images []string
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		images = appendImageValues(images, doc)
+	}
+	return uniqueImages(images), nil
+}
+
+// appendImageValues 递归查找 image 字段的字符串值
+func appendImageValues(images []string, node interface{}) []string {
+	switch value := node.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if image, ok := child.(string); ok && key == "image" {
+				if image = strings.TrimSpace(image); image != "" {
+					images = append(images, image)
+				}
+				continue
+			}
+			images = appendImageValues(images, child)
+		}
+	case []interface{}:
+		for _, child := range value {
+			images = appendImageValues(images, child)
+		}
+	}
+	return images
+}
+
+// helmDependencyUpdate 处理 helm dependency update
+// 临时改写 Chart.yaml 中依赖的仓库地址，执行结束后恢复 Chart.yaml，
+// 并把 Chart.lock 中的地址与摘要还原为原始仓库对应的值
+func helmDependencyUpdate(args []string, gitProxy *models.ProxyItem) error {
+	chartDir := "."
+	for _, arg := range args[2:] {
+		if !strings.HasPrefix(arg, "-") {
+			chartDir = arg
+			break
+		}
+	}
+
+	chartFile := filepath.Join(chartDir, "Chart.yaml")
+	original, err := os.ReadFile(chartFile)
+	if err != nil {
+		return fmt.Errorf("读取 Chart.yaml 失败: %w", err)
+	}
+
+	rewritten, replaced, err := rewriteChartDependencies(original, gitProxy)
+	if err != nil {
+		return err
+	}
+	if len(replaced) == 0 {
+		return runHelm(args)
+	}
+
+	if err := os.WriteFile(chartFile, rewritten, 0644); err != nil {
+		return fmt.Errorf("改写 Chart.yaml 失败: %w", err)
+	}
+
+	// helm 运行期间忽略中断信号，保证 Chart.yaml 一定会被恢复
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	runErr := runHelm(args)
+	signal.Stop(signals)
+
+	if err := os.WriteFile(chartFile, original, 0644); err != nil {
+		return fmt.Errorf("恢复 Chart.yaml 失败，请从以下内容手动恢复:\n%s\n%w", string(original), err)
+	}
+	if runErr != nil {
+		return runErr
+	}
+
+	return restoreChartLock(filepath.Join(chartDir, "Chart.lock"), original, replaced)
+}
+
+// rewriteChartDependencies 改写 Chart.yaml 中依赖的仓库地址
+// 返回: 改写后的内容、加速地址到原始地址的映射、错误
+func rewriteChartDependencies(data []byte, gitProxy *models.ProxyItem) ([]byte, map[string]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("解析 Chart.yaml 失败: %w", err)
+	}
+
+	replaced := make(map[string]string)
+	if len(doc.Content) == 0 {
+		return data, replaced, nil
+	}
+
+	deps := mappingValue(doc.Content[0], "dependencies")
+	if deps == nil || deps.Kind != yaml.SequenceNode {
+		return data, replaced, nil
+	}
+
+	for _, dep := range deps.Content {
+		repo := mappingValue(dep, "repository")
+		if repo == nil || repo.Kind != yaml.ScalarNode {
+			continue
+		}
+		accelerated := rewriteChartURL(repo.Value, gitProxy)
+		if accelerated != repo.Value {
+			replaced[accelerated] = repo.Value
+			repo.Value = accelerated
+		}
+	}
+
+	if len(replaced) == 0 {
+		return data, replaced, nil
+	}
+
+	rewritten, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("生成 Chart.yaml 失败: %w", err)
+	}
+	return rewritten, replaced, nil
+}
+
+// mappingValue 返回 YAML 映射节点中指定键的值，不存在时返回 nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// restoreChartLock 将 Chart.lock 中的加速地址还原为原始地址并重新计算摘要
+// 摘要算法与 Helm 一致: sha256(json([Chart.yaml 依赖, Chart.lock 依赖]))
+// chartYAML: 原始 Chart.yaml 内容
+// replaced: 加速地址到原始地址的映射
+func restoreChartLock(lockFile string, chartYAML []byte, replaced map[string]string) error {
+	data, err := os.ReadFile(lockFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("读取 Chart.lock 失败: %w", err)
+	}
+
+	var chart struct {
+		Dependencies []*helmDependency `yaml:"dependencies"`
+	}
+	if err := yaml.Unmarshal(chartYAML, &chart); err != nil {
+		return fmt.Errorf("解析 Chart.yaml 失败: %w", err)
+	}
+
+	var lock struct {
+		Digest       string            `yaml:"digest"`
+		Dependencies []*helmDependency `yaml:"dependencies"`
+	}
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return fmt.Errorf("解析 Chart.lock 失败: %w", err)
+	}
+
+	// 先用加速地址复算 helm 写入的摘要，确认算法一致后再替换
+	accelReq := make([]*helmDependency, len(chart.Dependencies))
+	for i, dep := range chart.Dependencies {
+		copied := *dep
+		for accelerated, original := range replaced {
+			if copied.Repository == original {
+				copied.Repository = accelerated
+			}
+		}
+		accelReq[i] = &copied
+	}
+	if digest, err := helmDependencyDigest(accelReq, lock.Dependencies); err != nil || digest != lock.Digest {
+		fmt.Fprintln(os.Stderr, "警告: 无法校验 Chart.lock 摘要，Chart.lock 中仍保留加速地址")
+		return nil
+	}
+
+	for _, dep := range lock.Dependencies {
+		if original, ok := replaced[dep.Repository]; ok {
+			dep.Repository = original
+		}
+	}
+	digest, err := helmDependencyDigest(chart.Dependencies, lock.Dependencies)
+	if err != nil {
+		return err
+	}
+
+	content := string(data)
+	for accelerated, original := range replaced {
+		content = strings.ReplaceAll(content, "repository: "+accelerated+"\n", "repository: "+original+"\n")
+	}
+	content = helmLockDigestRegexp.ReplaceAllString(content, "digest: "+digest)
+
+	if err := os.WriteFile(lockFile, []byte(content), 0644); err != nil {
+		return fmt.Errorf("写入 Chart.lock 失败: %w", err)
+	}
+	return nil
+}
+
+// helmDependencyDigest 计算 Chart.lock 中的依赖摘要
+func helmDependencyDigest(req, lock []*helmDependency) (string, error) {
+	data, err := json.Marshal([2][]*helmDependency{req, lock})
+	if err != nil {
+		return "", fmt.Errorf("计算依赖摘要失败: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,295 @@
+// Package services 包含 Kubernetes 准入 webhook 逻辑
+package services
+
+import (
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+	"cnfast/internal/pkg/util"
+
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultWebhookLabel 默认的 Pod 开启标签
+const defaultWebhookLabel = "cnfast.io/accelerate=true"
+
+// webhookMaxBody AdmissionReview 请求体的最大长度
+const webhookMaxBody = 10 << 20
+
+// admissionReview AdmissionReview 请求与响应（只包含需要的字段）
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+// admissionRequest 准入请求
+type admissionRequest struct {
+	UID         string          `json:"uid"`
+	Namespace   string          `json:"namespace"`
+	Operation   string          `json:"operation"`
+	SubResource string          `json:"subResource"`
+	Object      json.RawMessage `json:"object"`
+	OldObject   json.RawMessage `json:"oldObject"`
+}
+
+// admissionResponse 准入响应
+type admissionResponse struct {
+	UID       string `json:"uid"`
+	Allowed   bool   `json:"allowed"`
+	PatchType string `json:"patchType,omitempty"`
+	Patch     []byte `json:"patch,omitempty"`
+}
+
+// webhookPod Pod 中与镜像改写相关的字段
+type webhookPod struct {
+	Metadata struct {
+		Name         string            `json:"name"`
+		GenerateName string            `json:"generateName"`
+		Labels       map[string]string `json:"labels"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers          []webhookContainer `json:"containers"`
+		InitContainers      []webhookContainer `json:"initContainers"`
+		EphemeralContainers []webhookContainer `json:"ephemeralContainers"`
+	} `json:"spec"`
+}
+
+// webhookContainer 容器中与镜像改写相关的字段
+type webhookContainer struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// jsonPatchOp JSONPatch 操作
+type jsonPatchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// imageWebhook 镜像改写 webhook 的配置
+type imageWebhook struct {
+	// namespaces 开启加速的命名空间，包含 "*" 表示全部
+	namespaces map[string]bool
+
+	// labelKey、labelValue Pod 开启加速的标签；同名标签为其他值时表示关闭
+	labelKey   string
+	labelValue string
+
+	// excluded 不改写的镜像源域名
+	excluded map[string]bool
+}
+
+// K8sProxy 处理 cnfast k8s 命令
+// proxyList: 按优先顺序排列的 docker 代理列表，使用第一个代理改写镜像
+func K8sProxy(proxyList []models.ProxyItem) {
+	if len(os.Args) < 3 || os.Args[2] != "webhook" {
+		fmt.Fprintln(os.Stderr, "用法: cnfast k8s webhook [--listen :8443] [--tls-cert 证书 --tls-key 私钥] [--namespace ns,...] [--label key=value] [--exclude-registry 域名,...]")
+		os.Exit(1)
+	}
+	if len(proxyList) == 0 {
+		fmt.Fprintln(os.Stderr, "错误: 未找到可用的代理服务")
+		os.Exit(1)
+	}
+	useDockerProxy(&proxyList[0])
+
+	args := os.Args[3:]
+	listen, args, hasListen := util.ExtractFlagValue(args, "--listen")
+	certFile, args, _ := util.ExtractFlagValue(args, "--tls-cert")
+	keyFile, args, _ := util.ExtractFlagValue(args, "--tls-key")
+	namespaces, args, _ := util.ExtractFlagValue(args, "--namespace", "-n")
+	label, args, hasLabel := util.ExtractFlagValue(args, "--label")
+	excluded, args, _ := util.ExtractFlagValue(args, "--exclude-registry")
+
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "错误: 不支持的参数 '%s'\n", strings.Join(args, " "))
+		os.Exit(1)
+	}
+	if (certFile == "") != (keyFile == "") {
+		fmt.Fprintln(os.Stderr, "错误: --tls-cert 与 --tls-key 需要同时指定")
+		os.Exit(1)
+	}
+	if !hasListen {
+		listen = ":8443"
+	}
+	if !hasLabel {
+		label = defaultWebhookLabel
+	}
+
+	webhook := &imageWebhook{
+		namespaces: make(map[string]bool),
+		excluded:   make(map[string]bool),
+	}
+	for _, ns := range strings.Split(namespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			webhook.namespaces[ns] = true
+		}
+	}
+	for _, registry := range strings.Split(excluded, ",") {
+		if registry = strings.TrimSpace(registry); registry != "" {
+			webhook.excluded[registry] = true
+		}
+	}
+	if label != "" {
+		parts := strings.SplitN(label, "=", 2)
+		webhook.labelKey = parts[0]
+		webhook.labelValue = "true"
+		if len(parts) == 2 {
+			webhook.labelValue = parts[1]
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mutate", webhook.serveMutate)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+
+	fmt.Printf("镜像改写 webhook 已启动: %s（加速域名: %s）\n", listen, baseAccelDomain)
+	var err error
+	if certFile != "" {
+		err = http.ListenAndServeTLS(listen, certFile, keyFile, mux)
+	} else {
+		fmt.Println("提示: 未指定证书，使用 HTTP 监听，仅用于本地测试")
+		err = http.ListenAndServe(listen, mux)
+	}
+	fmt.Fprintf(os.Stderr, "错误: webhook 服务退出: %v\n", err)
+	os.Exit(1)
+}
+
+// serveMutate 处理 AdmissionReview 请求
+func (h *imageWebhook) serveMutate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "只支持 POST 请求", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, webhookMaxBody))
+	if err != nil {
+		http.Error(w, "读取请求失败", http.StatusBadRequest)
+		return
+	}
+
+	var review admissionReview
+	if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+		http.Error(w, "无效的 AdmissionReview 请求", http.StatusBadRequest)
+		return
+	}
+
+	response := &admissionResponse{UID: review.Request.UID, Allowed: true}
+	patch, err := h.mutate(review.Request)
+	if err != nil {
+		// 改写失败时放行原始 Pod，避免影响集群正常工作
+		fmt.Fprintf(os.Stderr, "警告: 处理准入请求 %s 失败: %v\n", review.Request.UID, err)
+	} else if len(patch) > 0 {
+		response.PatchType = "JSONPatch"
+		response.Patch = patch
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(admissionReview{
+		APIVersion: review.APIVersion,
+		Kind:       "AdmissionReview",
+		Response:   response,
+	})
+}
+
+// mutate 为开启加速的 Pod 生成镜像改写的 JSONPatch，无需改写时返回 nil
+// 新建 Pod 时改写全部容器；临时容器只能通过 pods/ephemeralcontainers 子资源的 UPDATE 添加，
+// 此时只改写新添加的临时容器
+func (h *imageWebhook) mutate(req *admissionRequest) ([]byte, error) {
+	// 其他 UPDATE 等操作原样放行：改写运行中 Pod 的镜像会重启容器，也可能与控制器的期望状态反复冲突
+	ephemeralUpdate := req.Operation == "UPDATE" && req.SubResource == "ephemeralcontainers"
+	if req.Operation != "CREATE" && !ephemeralUpdate {
+		return nil, nil
+	}
+
+	var pod webhookPod
+	if err := json.Unmarshal(req.Object, &pod); err != nil {
+		return nil, fmt.Errorf("解析 Pod 失败: %w", err)
+	}
+	if !h.enabled(req.Namespace, pod.Metadata.Labels) {
+		return nil, nil
+	}
+
+	name := pod.Metadata.Name
+	if name == "" {
+		name = pod.Metadata.GenerateName
+	}
+
+	type containerGroup struct {
+		field      string
+		containers []webhookContainer
+	}
+	groups := []containerGroup{
+		{"containers", pod.Spec.Containers},
+		{"initContainers", pod.Spec.InitContainers},
+		{"ephemeralContainers", pod.Spec.EphemeralContainers},
+	}
+
+	// 已存在的临时容器不允许修改，只改写本次新添加的
+	existing := make(map[string]bool)
+	if ephemeralUpdate {
+		groups = groups[2:]
+		if len(req.OldObject) > 0 {
+			var oldPod webhookPod
+			if err := json.Unmarshal(req.OldObject, &oldPod); err != nil {
+				return nil, fmt.Errorf("解析原 Pod 失败: %w", err)
+			}
+			for _, container := range oldPod.Spec.EphemeralContainers {
+				existing[container.Name] = true
+			}
+		}
+	}
+
+	var ops []jsonPatchOp
+	for _, group := range groups {
+		for i, container := range group.containers {
+			if existing[container.Name] {
+				continue
+			}
+			// 已是加速地址的镜像不在映射中，改写结果不变
+			accelerated := h.rewriteImage(container.Image)
+			if accelerated == container.Image {
+				continue
+			}
+			ops = append(ops, jsonPatchOp{
+				Op:    "replace",
+				Path:  fmt.Sprintf("/spec/%s/%d/image", group.field, i),
+				Value: accelerated,
+			})
+			fmt.Printf("[%s/%s] %s: %s -> %s\n", req.Namespace, name, container.Name, container.Image, accelerated)
+		}
+	}
+
+	if len(ops) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(ops)
+}
+
+// enabled 判断 Pod 是否开启加速
+// 命名空间在开启列表中或 Pod 带有开启标签时开启；Pod 的同名标签为其他值时关闭
+func (h *imageWebhook) enabled(namespace string, labels map[string]string) bool {
+	if h.labelKey != "" {
+		if value, ok := labels[h.labelKey]; ok {
+			return value == h.labelValue
+		}
+	}
+	return h.namespaces["*"] || h.namespaces[namespace]
+}
+
+// rewriteImage 改写单个镜像，排除的镜像源保持不变
+func (h *imageWebhook) rewriteImage(image string) string {
+	ref, err := reference.Parse(image)
+	if err != nil || h.excluded[ref.Domain] {
+		return image
+	}
+	return replaceImageWithSpecificDomain(image)
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWebhookMutate(t *testing.T) {
+	useTestRegistryMapping(t, "accel.example.com", nil, "")
+
+	webhook := &imageWebhook{
+		namespaces: map[string]bool{"dev": true},
+		labelKey:   "cnfast.io/accelerate",
+		labelValue: "true",
+		excluded:   map[string]bool{"harbor.corp.com": true},
+	}
+
+	pod := `{"metadata": {"name": "web", "labels": %s}, "spec": {
+		"initContainers": [{"name": "init", "image": "busybox"}],
+		"containers": [{"name": "web", "image": "nginx:1.25"}, {"name": "app", "image": "harbor.corp.com/team/app:1"}],
+		"ephemeralContainers": [%s]}}`
+	debugger := `{"name": "debug", "image": "busybox:1.36"}`
+	oldDebugger := `{"name": "old", "image": "alpine"}`
+
+	tests := []struct {
+		name      string
+		operation string
+		sub       string
+		namespace string
+		object    string
+		oldObject string
+		want      []jsonPatchOp
+	}{
+		{
+			name:      "命名空间开启时改写全部容器，排除的镜像源不变",
+			operation: "CREATE",
+			namespace: "dev",
+			object:    fmt.Sprintf(pod, `{}`, ""),
+			want: []jsonPatchOp{
+				{"replace", "/spec/containers/0/image", "accel.example.com/library/nginx:1.25"},
+				{"replace", "/spec/initContainers/0/image", "accel.example.com/library/busybox"},
+			},
+		},
+		{
+			name:      "标签开启",
+			operation: "CREATE",
+			namespace: "prod",
+			object:    fmt.Sprintf(pod, `{"cnfast.io/accelerate": "true"}`, ""),
+			want: []jsonPatchOp{
+				{"replace", "/spec/containers/0/image", "accel.example.com/library/nginx:1.25"},
+				{"replace", "/spec/initContainers/0/image", "accel.example.com/library/busybox"},
+			},
+		},
+		{
+			name:      "标签关闭优先于命名空间",
+			operation: "CREATE",
+			namespace: "dev",
+			object:    fmt.Sprintf(pod, `{"cnfast.io/accelerate": "false"}`, ""),
+		},
+		{
+			name:      "未开启的命名空间",
+			operation: "CREATE",
+			namespace: "prod",
+			object:    fmt.Sprintf(pod, `{}`, ""),
+		},
+		{
+			name:      "普通 UPDATE 原样放行",
+			operation: "UPDATE",
+			namespace: "dev",
+			object:    fmt.Sprintf(pod, `{}`, debugger),
+		},
+		{
+			name:      "添加临时容器时只改写新的临时容器",
+			operation: "UPDATE",
+			sub:       "ephemeralcontainers",
+			namespace: "dev",
+			object:    fmt.Sprintf(pod, `{}`, oldDebugger+","+debugger),
+			oldObject: fmt.Sprintf(pod, `{}`, oldDebugger),
+			want: []jsonPatchOp{
+				{"replace", "/spec/ephemeralContainers/1/image", "accel.example.com/library/busybox:1.36"},
+			},
+		},
+		{
+			name:      "已是加速地址的临时容器不改写",
+			operation: "UPDATE",
+			sub:       "ephemeralcontainers",
+			namespace: "dev",
+			object:    fmt.Sprintf(pod, `{}`, `{"name": "debug", "image": "accel.example.com/library/busybox"}`),
+			oldObject: fmt.Sprintf(pod, `{}`, ""),
+		},
+		{
+			name:      "DELETE 原样放行",
+			operation: "DELETE",
+			namespace: "dev",
+			object:    fmt.Sprintf(pod, `{}`, ""),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &admissionRequest{
+				UID:         "1",
+				Namespace:   tt.namespace,
+				Operation:   tt.operation,
+				SubResource: tt.sub,
+				Object:      json.RawMessage(tt.object),
+			}
+			if tt.oldObject != "" {
+				req.OldObject = json.RawMessage(tt.oldObject)
+			}
+
+			patch, err := webhook.mutate(req)
+			if err != nil {
+				t.Fatalf("mutate 返回错误: %v", err)
+			}
+
+			var got []jsonPatchOp
+			if patch != nil {
+				if err := json.Unmarshal(patch, &got); err != nil {
+					t.Fatalf("解析 JSONPatch 失败: %v", err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mutate() = %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookMutateInvalidObject(t *testing.T) {
+	webhook := &imageWebhook{namespaces: map[string]bool{"*": true}}
+	if _, err := webhook.mutate(&admissionRequest{Operation: "CREATE", Object: json.RawMessage(`[]`)}); err == nil {
+		t.Error("无效的 Pod 应返回错误")
+	}
+}
@@ -60,12 +60,29 @@ func (p *ProxyService) getProxyList(proxyType enums.ProxyType) ([]models.ProxyIt
 		fmt.Printf("成功获取 %d 个 %s 代理服务\n", len(proxyList), string(proxyType))
 	}
 
+	// 缓存代理列表，供补全代理 ID 使用
+	saveProxyCache(proxyType, proxyList)
+
 	return proxyList, nil
 }
 
 // handlerCmd 处理命令行参数并执行相应的操作
 // 返回 true 表示命令已处理，false 表示命令不支持
 func (p *ProxyService) handlerCmd() error {
+	// 以 git-remote-cnfast 名义被 git 调用时实现远程助手协议
+	if IsGitRemoteHelper() {
+		GitRemoteHelper(func() ([]models.ProxyItem, error) {
+			return p.getProxyList(enums.ServiceGit)
+		})
+		return nil
+	}
+
+	// 以 git、docker 等垫片名义被调用时，可加速的操作走加速逻辑，其余直接执行真实的命令
+	if tool := ShimTool(); tool != "" {
+		ShimCommand(tool, os.Args[1:], p.getProxyList)
+		return nil
+	}
+
 	// 没有参数时显示帮助信息
 	if len(os.Args) == 1 {
 		help.PrintHelp()
@@ -87,6 +104,26 @@ func (p *ProxyService) handlerCmd() error {
 		return p.handleDockerCommand(false)
 	case "git":
 		return p.handleGitCommand()
+	case "helm":
+		return p.handleHelmCommand()
+	case "k8s":
+		return p.handleK8sCommand()
+	case "rewrite":
+		return p.handleRewriteCommand()
+	case "devcontainer":
+		return p.handleDevcontainerCommand()
+	case "kind", "k3d", "minikube":
+		return p.handleLocalClusterCommand()
+	case "shims":
+		Shims(os.Args[2:])
+		return nil
+	case "completion":
+		Completion(os.Args[2:])
+		return nil
+	case "__complete":
+		// 供补全脚本调用，不在帮助信息中列出
+		Complete(os.Args[2:])
+		return nil
 	case "update":
 		return p.handleUpdate()
 	case "-v", "--version", "v", "version":
@@ -102,23 +139,58 @@ func (p *ProxyService) handlerCmd() error {
 
 // handleDockerCommand 处理 Docker 相关命令
 func (p *ProxyService) handleDockerCommand(isDocker bool) error {
+	// 离线命令无需获取代理列表
+	if isDocker && IsOfflineDockerCommand(os.Args[2:]) {
+		DockerOfflineCommand()
+		return nil
+	}
+
+	// 清理加速标签只需要代理列表来识别加速域名，无需选择代理
+	if isDocker && len(os.Args) >= 3 && os.Args[2] == "prune-accel" {
+		proxyList, err := p.getProxyList(enums.ServiceDocker)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "警告: 获取 Docker 代理服务失败，仅识别内置加速域名: %v\n", err)
+		}
+		DockerPruneAccel(os.Args[3:], proxyList)
+		return nil
+	}
+
+	// run/create 只在本地缺少镜像时才需要代理，且不提示选择，以免读取容器需要的标准输入
+	if isDocker && IsDockerRunCommand(os.Args[2:]) {
+		DockerRun(os.Args[2:], func() ([]models.ProxyItem, error) {
+			proxyList, err := p.getProxyList(enums.ServiceDocker)
+			if err != nil {
+				return nil, err
+			}
+			return sortProxiesByScore(proxyList), nil
+		})
+		return nil
+	}
+
 	// 获取 Docker 代理列表
 	proxyList, err := p.getProxyList(enums.ServiceDocker)
 	if err != nil {
 		return fmt.Errorf("获取 Docker 代理服务失败: %w", err)
 	}
 
-	// 让用户选择 Docker 代理
+	// 让用户选择 Docker 代理，其余代理作为失败时的备选
 	selectedProxy := selectProxyWithPrompt(proxyList)
-	selectedList := []models.ProxyItem{selectedProxy}
 
 	// 执行 Docker 代理
-	DockerProxy(selectedList, isDocker)
+	DockerProxy(preferProxy(proxyList, selectedProxy), isDocker)
 	return nil
 }
 
 // handleGitCommand 处理 Git 相关命令
 func (p *ProxyService) handleGitCommand() error {
+	// setup --revert 无需代理服务，代理列表在写入规则时再获取
+	if len(os.Args) >= 3 && os.Args[2] == "setup" {
+		GitSetup(os.Args[3:], func() ([]models.ProxyItem, error) {
+			return p.getProxyList(enums.ServiceGit)
+		})
+		return nil
+	}
+
 	// 获取 Git 代理列表
 	proxyList, err := p.getProxyList(enums.ServiceGit)
 	if err != nil {
@@ -130,6 +202,88 @@ func (p *ProxyService) handleGitCommand() error {
 	return nil
 }
 
+// handleHelmCommand 处理 Helm 相关命令
+// 同时使用 git 代理（GitHub 托管的 chart 仓库）与 docker 代理（OCI chart 与镜像预拉取），
+// 任一类型获取失败时只跳过对应的加速
+func (p *ProxyService) handleHelmCommand() error {
+	var gitProxy *models.ProxyItem
+	gitList, gitErr := p.getProxyList(enums.ServiceGit)
+	if gitErr == nil {
+		gitProxy = &sortProxiesByScore(gitList)[0]
+	}
+
+	dockerList, dockerErr := p.getProxyList(enums.ServiceDocker)
+	if dockerErr == nil {
+		dockerList = sortProxiesByScore(dockerList)
+	}
+
+	if gitErr != nil && dockerErr != nil {
+		return fmt.Errorf("获取加速服务失败: %v; %v", gitErr, dockerErr)
+	}
+	if gitErr != nil {
+		fmt.Fprintf(os.Stderr, "警告: 获取 Git 代理服务失败，GitHub 地址不加速: %v\n", gitErr)
+	}
+	if dockerErr != nil {
+		fmt.Fprintf(os.Stderr, "警告: 获取 Docker 代理服务失败，OCI 地址不加速: %v\n", dockerErr)
+	}
+
+	HelmProxy(gitProxy, dockerList)
+	return nil
+}
+
+// handleK8sCommand 处理 Kubernetes 相关命令
+// webhook 长期运行，直接使用评分最高的 Docker 代理，不提示选择
+func (p *ProxyService) handleK8sCommand() error {
+	proxyList, err := p.getProxyList(enums.ServiceDocker)
+	if err != nil {
+		return fmt.Errorf("获取 Docker 代理服务失败: %w", err)
+	}
+
+	K8sProxy(sortProxiesByScore(proxyList))
+	return nil
+}
+
+// handleRewriteCommand 处理清单文件改写命令
+// 代理列表只用于生成和识别加速地址，获取失败时使用内置加速域名
+func (p *ProxyService) handleRewriteCommand() error {
+	proxyList, err := p.getProxyList(enums.ServiceDocker)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: 获取 Docker 代理服务失败，使用内置加速域名: %v\n", err)
+	} else {
+		proxyList = sortProxiesByScore(proxyList)
+	}
+
+	RewriteCommand(proxyList)
+	return nil
+}
+
+// handleDevcontainerCommand 处理 devcontainer 相关命令
+func (p *ProxyService) handleDevcontainerCommand() error {
+	proxyList, err := p.getProxyList(enums.ServiceDocker)
+	if err != nil {
+		return fmt.Errorf("获取 Docker 代理服务失败: %w", err)
+	}
+
+	// 与 docker 命令一致，让用户选择代理，其余代理作为失败时的备选
+	selectedProxy := selectProxyWithPrompt(proxyList)
+
+	DevcontainerProxy(preferProxy(proxyList, selectedProxy))
+	return nil
+}
+
+// handleLocalClusterCommand 处理 kind、k3d、minikube 镜像导入命令
+func (p *ProxyService) handleLocalClusterCommand() error {
+	proxyList, err := p.getProxyList(enums.ServiceDocker)
+	if err != nil {
+		return fmt.Errorf("获取 Docker 代理服务失败: %w", err)
+	}
+
+	selectedProxy := selectProxyWithPrompt(proxyList)
+
+	LocalClusterLoad(preferProxy(proxyList, selectedProxy))
+	return nil
+}
+
 // handleUpdate 处理 cnfast 自更新命令
 // 通过从 releases/latest 下载安装脚本并执行，实现与 install.sh 一致的更新逻辑
 func (p *ProxyService) handleUpdate() error {
@@ -0,0 +1,358 @@
+// Package services 包含离线改写清单文件中镜像地址的逻辑
+package services
+
+import (
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/reference"
+
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// textEdit 对原始文本的一处修改
+type textEdit struct {
+	// start、end 被替换内容的字节偏移，二者相等时为插入
+	start int
+	end   int
+
+	// text 新内容
+	text string
+}
+
+// imageRewriter 改写 YAML 文本中的镜像地址
+// 通过 yaml.v3 定位镜像字段，只替换对应的文本片段，注释、顺序与格式保持不变
+type imageRewriter struct {
+	// data 原始文本
+	data []byte
+
+	// lineStarts 每行起始位置的字节偏移
+	lineStarts []int
+
+	// newline 原始文本使用的换行符，插入新行时沿用
+	newline string
+
+	// mapImage 镜像名映射函数，无需改写时返回原值
+	mapImage func(string) string
+
+	// edits 待应用的修改
+	edits []textEdit
+
+	// count 改写的镜像数量
+	count int
+}
+
+// RewriteCommand 处理 cnfast rewrite 命令
+// proxyList: docker 代理列表，用于生成加速地址以及识别加速域名，可以为空
+func RewriteCommand(proxyList []models.ProxyItem) {
+	if len(os.Args) < 3 || os.Args[2] != "images" {
+		printRewriteUsage()
+		os.Exit(1)
+	}
+
+	var files []string
+	var inPlace, reverse bool
+	args := os.Args[3:]
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-f" || arg == "--file":
+			if i+1 >= len(args) {
+				printRewriteUsage()
+				os.Exit(1)
+			}
+			files = append(files, args[i+1])
+			i++
+		case strings.HasPrefix(arg, "--file="):
+			files = append(files, strings.TrimPrefix(arg, "--file="))
+		case arg == "-i" || arg == "--in-place":
+			inPlace = true
+		case arg == "--reverse":
+			reverse = true
+		default:
+			fmt.Fprintf(os.Stderr, "错误: 不支持的参数 '%s'\n", arg)
+			printRewriteUsage()
+			os.Exit(1)
+		}
+	}
+	if len(files) == 0 {
+		printRewriteUsage()
+		os.Exit(1)
+	}
+
+	var mapImage func(string) string
+	if reverse {
+		patterns := buildAccelPatterns(proxyList)
+		mapImage = func(image string) string {
+			return originalImageName(image, patterns)
+		}
+	} else {
+		if len(proxyList) > 0 {
+			useDockerProxy(&proxyList[0])
+		}
+		mapImage = replaceImageWithSpecificDomain
+	}
+
+	var previous []byte
+	for _, file := range files {
+		var data []byte
+		var err error
+		if file == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 读取 %s 失败: %v\n", file, err)
+			os.Exit(1)
+		}
+
+		output, count, err := rewriteYAMLImages(data, mapImage)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: 解析 %s 失败: %v\n", file, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "%s: 改写 %d 个镜像\n", file, count)
+
+		if inPlace && file != "-" {
+			if err := os.WriteFile(file, output, 0644); err != nil {
+				fmt.Fprintf(os.Stderr, "错误: 写入 %s 失败: %v\n", file, err)
+				os.Exit(1)
+			}
+			continue
+		}
+
+		// 多个文件输出到标准输出时以文档分隔符连接，分隔符必须独占一行，并沿用上一个文件的换行符
+		if previous != nil {
+			newline := detectNewline(previous)
+			if len(previous) > 0 && !bytes.HasSuffix(previous, []byte("\n")) {
+				fmt.Print(newline)
+			}
+			fmt.Print("---" + newline)
+		}
+		os.Stdout.Write(output)
+		previous = output
+	}
+}
+
+// detectNewline 返回文本使用的换行符，包含 CRLF 时为 "\r\n"，否则为 "\n"
+func detectNewline(data []byte) string {
+	if bytes.Contains(data, []byte("\r\n")) {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// printRewriteUsage 输出 rewrite 命令用法
+func printRewriteUsage() {
+	fmt.Fprintln(os.Stderr, "用法: cnfast rewrite images -f <文件>... [-i] [--reverse]")
+	fmt.Fprintln(os.Stderr, "  -f, --file <文件>  需要改写的 YAML 文件，可以指定多次，- 表示标准输入")
+	fmt.Fprintln(os.Stderr, "  -i, --in-place     直接修改文件，默认输出到标准输出")
+	fmt.Fprintln(os.Stderr, "  --reverse          将加速地址还原为原始镜像名")
+}
+
+// originalImageName 将加速地址还原为原始镜像名，不是加速地址时原样返回
+func originalImageName(image string, patterns []accelPattern) string {
+	ref, err := reference.Parse(image)
+	if err != nil {
+		return image
+	}
+	original, ok := originalRepository(ref.Name(), patterns)
+	if !ok {
+		return image
+	}
+	return original + ref.Suffix()
+}
+
+// rewriteYAMLImages 改写 YAML 文本（可包含多个文档）中的镜像地址
+// 支持任意层级的 image 字段（Kubernetes 容器、compose 服务）与 Kustomize 的 images 列表
+// 返回: 改写后的文本、改写的镜像数量、错误
+func rewriteYAMLImages(data []byte, mapImage func(string) string) ([]byte, int, error) {
+	r := &imageRewriter{data: data, mapImage: mapImage, lineStarts: []int{0}, newline: detectNewline(data)}
+	for i, b := range data {
+		if b == '\n' {
+			r.lineStarts = append(r.lineStarts, i+1)
+		}
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		root := doc.Content[0]
+		r.rewriteKustomizeImages(root)
+		r.walk(root)
+	}
+
+	return r.apply(), r.count, nil
+}
+
+// walk 递归查找 image 字段
+func (r *imageRewriter) walk(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "image" && value.Kind == yaml.ScalarNode {
+				r.replaceScalar(value, r.mapImage(value.Value))
+				continue
+			}
+			r.walk(value)
+		}
+	case yaml.SequenceNode:
+		for _, child := range node.Content {
+			r.walk(child)
+		}
+	}
+}
+
+// rewriteKustomizeImages 改写 Kustomize 顶层的 images 列表
+// name 用于匹配资源中的镜像，必须保持不变，因此改写（或添加）newName
+func (r *imageRewriter) rewriteKustomizeImages(root *yaml.Node) {
+	images := mappingValue(root, "images")
+	if images == nil || images.Kind != yaml.SequenceNode {
+		return
+	}
+
+	for _, item := range images.Content {
+		name := mappingValue(item, "name")
+		if name == nil || name.Kind != yaml.ScalarNode {
+			continue
+		}
+
+		if newName := mappingValue(item, "newName"); newName != nil {
+			if newName.Kind == yaml.ScalarNode {
+				r.replaceScalar(newName, r.mapImage(newName.Value))
+			}
+			continue
+		}
+
+		mapped := r.mapImage(name.Value)
+		if mapped == name.Value {
+			continue
+		}
+
+		// 流式写法（如 {name: nginx}）在 name 的值之后追加同级的 newName
+		if item.Style&yaml.FlowStyle != 0 || images.Style&yaml.FlowStyle != 0 {
+			_, end, ok := r.scalarSpan(name)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "警告: 第 %d 行的 Kustomize 镜像 %s 无法定位，已跳过\n", name.Line, name.Value)
+				continue
+			}
+			r.edits = append(r.edits, textEdit{start: end, end: end, text: ", newName: " + mapped})
+			r.count++
+			continue
+		}
+
+		// 在 name 所在行之后插入同级的 newName，沿用原文本的换行符
+		nameKey := mappingKey(item, "name")
+		lineEnd := len(r.data)
+		if name.Line < len(r.lineStarts) {
+			lineEnd = r.lineStarts[name.Line] - 1
+			if lineEnd > 0 && r.data[lineEnd-1] == '\r' {
+				lineEnd--
+			}
+		}
+		indent := strings.Repeat(" ", nameKey.Column-1)
+		r.edits = append(r.edits, textEdit{start: lineEnd, end: lineEnd, text: r.newline + indent + "newName: " + mapped})
+		r.count++
+	}
+}
+
+// mappingKey 返回 YAML 映射节点中指定的键节点，不存在时返回 nil
+func mappingKey(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i]
+		}
+	}
+	return nil
+}
+
+// replaceScalar 替换标量节点在原始文本中的内容，保留原有的引号风格
+// 块标量等无法安全定位的写法会被跳过
+func (r *imageRewriter) replaceScalar(node *yaml.Node, value string) {
+	if value == node.Value {
+		return
+	}
+	start, end, ok := r.scalarSpan(node)
+	if !ok {
+		return
+	}
+	// 引号风格保持不变
+	var quote string
+	if node.Style != 0 {
+		quote = string(r.data[start])
+	}
+	r.edits = append(r.edits, textEdit{start: start, end: end, text: quote + value + quote})
+	r.count++
+}
+
+// scalarSpan 返回标量节点（含引号）在原始文本中的字节范围
+// 块标量、含转义等与解析值不一致的写法返回 false
+func (r *imageRewriter) scalarSpan(node *yaml.Node) (int, int, bool) {
+	if node.Line < 1 || node.Line > len(r.lineStarts) {
+		return 0, 0, false
+	}
+
+	var quote string
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		quote = `"`
+	case yaml.SingleQuotedStyle:
+		quote = `'`
+	case 0:
+	default:
+		return 0, 0, false
+	}
+	raw := quote + node.Value + quote
+
+	start := r.offset(node.Line, node.Column)
+	if !bytes.HasPrefix(r.data[start:], []byte(raw)) {
+		return 0, 0, false
+	}
+	return start, start + len(raw), true
+}
+
+// offset 将行号与列号（均从 1 开始，列按字符计）转换为字节偏移
+func (r *imageRewriter) offset(line, column int) int {
+	if line > len(r.lineStarts) {
+		return len(r.data)
+	}
+	pos := r.lineStarts[line-1]
+	for i := 1; i < column && pos < len(r.data); i++ {
+		_, size := utf8.DecodeRune(r.data[pos:])
+		pos += size
+	}
+	return pos
+}
+
+// apply 按偏移从后向前应用全部修改
+func (r *imageRewriter) apply() []byte {
+	sort.Slice(r.edits, func(i, j int) bool {
+		return r.edits[i].start > r.edits[j].start
+	})
+
+	output := append([]byte(nil), r.data...)
+	for _, edit := range r.edits {
+		output = append(output[:edit.start], append([]byte(edit.text), output[edit.end:]...)...)
+	}
+	return output
+}
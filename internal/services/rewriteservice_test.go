@@ -0,0 +1,132 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRewriteYAMLImages(t *testing.T) {
+	mapImage := func(image string) string {
+		if strings.HasPrefix(image, "nginx") || strings.HasPrefix(image, "redis") {
+			return "accel.example.com/library/" + image
+		}
+		return image
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		count int
+	}{
+		{
+			name: "Kubernetes 容器，保留注释与引号",
+			input: `apiVersion: v1
+kind: Pod
+spec:
+  containers:
+    - name: web # 注释
+      image: "nginx:1.25"
+    - name: app
+      image: 'harbor.corp.com/app:1'
+`,
+			want: `apiVersion: v1
+kind: Pod
+spec:
+  containers:
+    - name: web # 注释
+      image: "accel.example.com/library/nginx:1.25"
+    - name: app
+      image: 'harbor.corp.com/app:1'
+`,
+			count: 1,
+		},
+		{
+			name:  "多文档与 compose",
+			input: "services:\n  cache:\n    image: redis:7\n---\nspec:\n  template:\n    spec:\n      containers: [{name: web, image: nginx}]\n",
+			want:  "services:\n  cache:\n    image: accel.example.com/library/redis:7\n---\nspec:\n  template:\n    spec:\n      containers: [{name: web, image: accel.example.com/library/nginx}]\n",
+			count: 2,
+		},
+		{
+			name:  "Kustomize 添加 newName",
+			input: "images:\n  - name: nginx\n    newTag: \"1.25\"\n  - name: busybox\n",
+			want:  "images:\n  - name: nginx\n    newName: accel.example.com/library/nginx\n    newTag: \"1.25\"\n  - name: busybox\n",
+			count: 1,
+		},
+		{
+			name:  "Kustomize 改写已有的 newName",
+			input: "images:\n- name: web\n  newName: redis\n",
+			want:  "images:\n- name: web\n  newName: accel.example.com/library/redis\n",
+			count: 1,
+		},
+		{
+			name:  "Kustomize 流式映射",
+			input: "images:\n- {name: nginx, newTag: \"1\"}\n- {name: 'redis:7'}\n",
+			want:  "images:\n- {name: nginx, newName: accel.example.com/library/nginx, newTag: \"1\"}\n- {name: 'redis:7', newName: accel.example.com/library/redis:7}\n",
+			count: 2,
+		},
+		{
+			name:  "Kustomize 流式列表",
+			input: "images: [{name: \"nginx\"}]\n",
+			want:  "images: [{name: \"nginx\", newName: accel.example.com/library/nginx}]\n",
+			count: 1,
+		},
+		{
+			name:  "CRLF 换行保持不变",
+			input: "images:\r\n- name: nginx # 注释\r\n  newTag: \"1\"\r\nspec:\r\n  image: redis\r\n",
+			want:  "images:\r\n- name: nginx # 注释\r\n  newName: accel.example.com/library/nginx\r\n  newTag: \"1\"\r\nspec:\r\n  image: accel.example.com/library/redis\r\n",
+			count: 2,
+		},
+		{
+			name:  "文件末尾没有换行",
+			input: "images:\n- name: nginx",
+			want:  "images:\n- name: nginx\n  newName: accel.example.com/library/nginx",
+			count: 1,
+		},
+		{
+			name:  "块标量跳过",
+			input: "image: |\n  nginx\n",
+			want:  "image: |\n  nginx\n",
+			count: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, count, err := rewriteYAMLImages([]byte(tt.input), mapImage)
+			if err != nil {
+				t.Fatalf("rewriteYAMLImages 返回错误: %v", err)
+			}
+			if string(output) != tt.want {
+				t.Errorf("输出:\n%q\n期望:\n%q", output, tt.want)
+			}
+			if count != tt.count {
+				t.Errorf("改写数量 %d, 期望 %d", count, tt.count)
+			}
+		})
+	}
+}
+
+func TestRewriteYAMLImagesInvalid(t *testing.T) {
+	if _, _, err := rewriteYAMLImages([]byte("image: [nginx\n"), func(s string) string { return s }); err == nil {
+		t.Error("无效的 YAML 应返回错误")
+	}
+}
+
+func TestDetectNewline(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"a: 1\n", "\n"},
+		{"a: 1\r\nb: 2\r\n", "\r\n"},
+		{"a: 1", "\n"},
+		{"", "\n"},
+	}
+
+	for _, tt := range tests {
+		if got := detectNewline([]byte(tt.data)); got != tt.want {
+			t.Errorf("detectNewline(%q) = %q, 期望 %q", tt.data, got, tt.want)
+		}
+	}
+}
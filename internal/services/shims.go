@@ -0,0 +1,415 @@
+// Package services 包含 git、docker、curl 命令垫片的逻辑
+package services
+
+import (
+	"cnfast/config"
+	"cnfast/internal/enums"
+	"cnfast/internal/models"
+	"cnfast/internal/pkg/util"
+
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// shimTools 以垫片方式接管的命令
+var shimTools = []string{"git", "docker", "docker-compose", "curl"}
+
+// composeValueFlags docker compose 子命令之前需要携带参数值的全局选项
+var composeValueFlags = []string{
+	"-f", "--file",
+	"-p", "--project-name",
+	"--profile",
+	"--env-file",
+	"--project-directory",
+	"--ansi",
+	"--progress",
+	"--parallel",
+}
+
+// composePrefetchCommands 会拉取缺少镜像的 docker compose 子命令
+var composePrefetchCommands = []string{"up", "create", "run"}
+
+// defaultShimDir 默认的垫片目录（~/.cnfast/shims）
+func defaultShimDir() string {
+	return filepath.Join(config.HomeDir, "shims")
+}
+
+// ShimTool 返回当前以垫片名义被调用时的命令名，否则返回空字符串
+func ShimTool() string {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	if isCommandSupported(name, shimTools) {
+		return name
+	}
+	return ""
+}
+
+// ShimCommand 处理以垫片名义被调用的命令
+// 可加速的操作交给对应的加速逻辑（不提示选择代理），其余操作直接执行真实的命令
+// tool: 垫片对应的命令名
+// args: 命令之后的全部参数
+// loadProxies: 获取指定类型的代理列表，只在需要加速时调用
+func ShimCommand(tool string, args []string, loadProxies func(enums.ProxyType) ([]models.ProxyItem, error)) {
+	// 从 PATH 中移除垫片目录，使本进程及子进程执行的都是真实的命令
+	removeShimDirFromPath()
+	if err := checkRealTool(tool); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	sortedLoader := func(proxyType enums.ProxyType) func() ([]models.ProxyItem, error) {
+		return func() ([]models.ProxyItem, error) {
+			proxyList, err := loadProxies(proxyType)
+			if err != nil {
+				return nil, err
+			}
+			return sortProxiesByScore(proxyList), nil
+		}
+	}
+
+	switch tool {
+	case "git":
+		if len(args) > 0 && (args[0] == "clone" || args[0] == "pull") && hasGitHubURL(args) {
+			if proxyList, ok := loadShimProxies(sortedLoader(enums.ServiceGit), "Git"); ok {
+				os.Args = append([]string{os.Args[0], "git"}, args...)
+				executeGitWithProxyRetry(proxyList, args[0])
+				return
+			}
+		}
+	case "docker":
+		switch {
+		case IsDockerRunCommand(args):
+			DockerRun(args, sortedLoader(enums.ServiceDocker))
+			return
+		case len(args) > 0 && args[0] == "pull",
+			len(args) > 1 && args[0] == "buildx" && args[1] == "build":
+			if proxyList, ok := loadShimProxies(sortedLoader(enums.ServiceDocker), "Docker"); ok {
+				os.Args = append([]string{os.Args[0], "docker"}, args...)
+				DockerProxy(proxyList, true)
+				return
+			}
+		case len(args) > 0 && args[0] == "compose":
+			prefetchComposeImages(args[1:], sortedLoader(enums.ServiceDocker))
+		}
+	case "docker-compose":
+		prefetchComposeImages(args, sortedLoader(enums.ServiceDocker))
+	case "curl":
+		if hasGitHubURL(args) {
+			if proxyList, ok := loadShimProxies(sortedLoader(enums.ServiceGit), "Git"); ok {
+				curlWithProxyRetry(args, proxyList)
+				return
+			}
+		}
+	}
+
+	if config.Debug {
+		fmt.Fprintf(os.Stderr, "执行命令: %s %s\n", tool, strings.Join(args, " "))
+	}
+	execCommand(tool, args)
+}
+
+// loadShimProxies 获取代理列表，失败时提示并改为直接执行命令
+func loadShimProxies(loadProxies func() ([]models.ProxyItem, error), name string) ([]models.ProxyItem, bool) {
+	proxyList, err := loadProxies()
+	if err != nil || len(proxyList) == 0 {
+		fmt.Fprintf(os.Stderr, "警告: 获取 %s 代理服务失败，不使用加速: %v\n", name, err)
+		return nil, false
+	}
+	return proxyList, true
+}
+
+// hasGitHubURL 判断参数中是否包含 GitHub 地址
+func hasGitHubURL(args []string) bool {
+	for _, arg := range args {
+		if isGitHubURL(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// curlWithProxyRetry 将参数中的 GitHub 地址替换为加速地址后执行 curl，失败时切换代理
+// 其余参数原样保留
+func curlWithProxyRetry(args []string, proxyList []models.ProxyItem) {
+	ExecuteWithProxyRetry(proxyList, func(proxy models.ProxyItem) (*exec.Cmd, string, error) {
+		newArgs := make([]string, 0, len(args))
+		for _, arg := range args {
+			if isGitHubURL(arg) {
+				arg = renderGitURL(proxy, arg)
+			}
+			newArgs = append(newArgs, arg)
+		}
+
+		host := util.ExtractHostFromURL(proxy.ProxyUrl)
+		if config.Debug {
+			fmt.Fprintf(os.Stderr, "执行命令: curl %s\n", strings.ReplaceAll(strings.Join(newArgs, " "), host, "***"))
+		}
+		return exec.Command("curl", newArgs...), host, nil
+	}, "下载")
+}
+
+// prefetchComposeImages 在 docker compose up/create/run 之前经加速域名拉取本地缺少的镜像
+// 预拉取失败不会中断命令，docker compose 会自行拉取镜像
+// args: compose 之后的全部参数
+func prefetchComposeImages(args []string, loadProxies func() ([]models.ProxyItem, error)) {
+	composeFile := ""
+	command := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			command = arg
+			break
+		}
+		if isCommandSupported(arg, composeValueFlags) && i+1 < len(args) {
+			if (arg == "-f" || arg == "--file") && composeFile == "" {
+				composeFile = args[i+1]
+			}
+			i++
+		}
+	}
+	if !isCommandSupported(command, composePrefetchCommands) {
+		return
+	}
+
+	if composeFile == "" {
+		composeFile = findComposeFile()
+	}
+	if composeFile == "" {
+		return
+	}
+
+	composeImages, err := loadComposeImages(composeFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v，跳过预拉取\n", err)
+		return
+	}
+	var missing []string
+	for _, item := range composeImages {
+		if !localImageAvailable(item.Image, "") {
+			missing = append(missing, item.Image)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	proxyList, ok := loadShimProxies(loadProxies, "Docker")
+	if !ok {
+		return
+	}
+	activeImageLock, err = loadImageLockFile(defaultLockFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "警告: %v，跳过预拉取\n", err)
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "预拉取 %d 个镜像\n", len(missing))
+	results := pullWithFailover(missing, proxyList, func(pending []string) []pullResult {
+		return pullImages(pending, nil, config.PullConcurrency)
+	})
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Fprintf(os.Stderr, "警告: %s 预拉取失败，由 docker compose 直接拉取: %v\n", result.Image, result.Err)
+		}
+	}
+}
+
+// removeShimDirFromPath 从 PATH 中移除垫片所在目录与默认垫片目录
+func removeShimDirFromPath() {
+	dirs := []string{defaultShimDir()}
+	if filepath.Base(os.Args[0]) != os.Args[0] {
+		dirs = append(dirs, filepath.Dir(os.Args[0]))
+	} else if path, err := exec.LookPath(os.Args[0]); err == nil {
+		dirs = append(dirs, filepath.Dir(path))
+	}
+
+	var kept []string
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if !containsPath(dirs, entry) {
+			kept = append(kept, entry)
+		}
+	}
+	os.Setenv("PATH", strings.Join(kept, string(os.PathListSeparator)))
+}
+
+// containsPath 判断路径列表中是否包含指定目录
+func containsPath(dirs []string, dir string) bool {
+	dir = absPath(dir)
+	for _, item := range dirs {
+		item = absPath(item)
+		if item == dir || (runtime.GOOS == "windows" && strings.EqualFold(item, dir)) {
+			return true
+		}
+	}
+	return false
+}
+
+// absPath 返回清理后的绝对路径，失败时返回清理后的原路径
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// checkRealTool 确认 PATH 中能找到真实的命令，且不是 cnfast 自身，避免递归调用
+func checkRealTool(tool string) error {
+	path, err := exec.LookPath(tool)
+	if err != nil {
+		return fmt.Errorf("未找到真实的 %s 命令: %w", tool, err)
+	}
+	if isCnfastBinary(path) {
+		return fmt.Errorf("PATH 中的 %s 指向 cnfast（%s），请只将垫片目录加入 PATH", tool, path)
+	}
+	return nil
+}
+
+// isCnfastBinary 判断文件是否为当前运行的 cnfast 程序（符号链接或硬链接）
+func isCnfastBinary(path string) bool {
+	exe, err := os.Executable()
+	if err != nil {
+		return false
+	}
+	exeInfo, err := os.Stat(exe)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && os.SameFile(exeInfo, info)
+}
+
+// Shims 处理 cnfast shims install|uninstall 命令
+// args: shims 之后的全部参数
+func Shims(args []string) {
+	dir, args, hasDir := util.ExtractFlagValue(args, "--dir")
+	if !hasDir {
+		dir = defaultShimDir()
+	}
+	if len(args) != 1 {
+		printShimsUsage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch args[0] {
+	case "install":
+		err = installShims(dir)
+	case "uninstall":
+		err = uninstallShims(dir)
+	default:
+		fmt.Fprintf(os.Stderr, "错误: 不支持的命令 '%s'\n", args[0])
+		printShimsUsage()
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// shimNames 垫片目录中的文件名，同时安装 git-remote-cnfast 远程助手
+func shimNames() []string {
+	names := append(append([]string{}, shimTools...), gitRemoteHelperName)
+	if runtime.GOOS == "windows" {
+		for i := range names {
+			names[i] += ".exe"
+		}
+	}
+	return names
+}
+
+// installShims 在垫片目录中创建指向 cnfast 的链接
+// 已存在的旧垫片会被替换，其他同名文件不会被覆盖
+func installShims(dir string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("获取 cnfast 路径失败: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建垫片目录失败: %w", err)
+	}
+
+	for _, name := range shimNames() {
+		path := filepath.Join(dir, name)
+		if _, err := os.Lstat(path); err == nil {
+			if !isShimLink(path) {
+				return fmt.Errorf("%s 已存在且不是 cnfast 垫片", path)
+			}
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("删除旧垫片失败: %w", err)
+			}
+		}
+
+		// Windows 创建符号链接需要开发者模式或管理员权限，失败时改用硬链接
+		if err := os.Symlink(exe, path); err != nil {
+			if linkErr := os.Link(exe, path); linkErr != nil {
+				return fmt.Errorf("创建垫片 %s 失败: %v", path, err)
+			}
+		}
+		fmt.Printf("已创建 %s -> %s\n", path, exe)
+	}
+
+	fmt.Println()
+	fmt.Println("请将垫片目录加入 PATH 的最前面，例如:")
+	if runtime.GOOS == "windows" {
+		fmt.Printf("  $env:Path = \"%s;\" + $env:Path\n", dir)
+	} else {
+		fmt.Printf("  export PATH=\"%s:$PATH\"\n", dir)
+	}
+	fmt.Println("不需要加速的操作会直接执行真实的命令；撤销: cnfast shims uninstall")
+	return nil
+}
+
+// uninstallShims 删除垫片目录中的 cnfast 垫片，目录为空时一并删除
+func uninstallShims(dir string) error {
+	removed := 0
+	for _, name := range shimNames() {
+		path := filepath.Join(dir, name)
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+		if !isShimLink(path) {
+			fmt.Fprintf(os.Stderr, "警告: %s 不是 cnfast 垫片，已跳过\n", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("删除垫片失败: %w", err)
+		}
+		fmt.Printf("已删除 %s\n", path)
+		removed++
+	}
+
+	if removed == 0 {
+		fmt.Println("没有需要删除的垫片")
+	}
+	os.Remove(dir)
+	fmt.Println("如已将垫片目录加入 PATH，请同时从 shell 配置中移除")
+	return nil
+}
+
+// isShimLink 判断文件是否为 cnfast 垫片: 指向 cnfast 的链接，或目标已不存在的符号链接（cnfast 已移动）
+func isShimLink(path string) bool {
+	if isCnfastBinary(path) {
+		return true
+	}
+	info, err := os.Lstat(path)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	_, err = os.Stat(path)
+	return os.IsNotExist(err)
+}
+
+// printShimsUsage 输出 shims 命令用法
+func printShimsUsage() {
+	fmt.Fprintln(os.Stderr, "用法: cnfast shims install|uninstall [--dir 垫片目录]")
+	fmt.Fprintf(os.Stderr, "  install    在垫片目录中创建 %s 指向 cnfast 的链接\n", strings.Join(shimTools, "、"))
+	fmt.Fprintln(os.Stderr, "  uninstall  删除 cnfast 创建的垫片")
+	fmt.Fprintf(os.Stderr, "  --dir      垫片目录，默认 %s\n", defaultShimDir())
+}